@@ -0,0 +1,68 @@
+package bls
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// revealMethods -- methods that export secret key material
+var revealMethods = map[string]bool{"Reveal": true, "RevealHex": true}
+
+// TestRevealAudit -- every call that reveals a Seckey has to be justified by a "reveal:" comment
+// on the same or the preceding line. Test files are exempt.
+func TestRevealAudit(t *testing.T) {
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		justified := make(map[int]bool)
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if strings.Contains(c.Text, "reveal:") {
+					line := fset.Position(c.Pos()).Line
+					justified[line] = true
+					justified[line+1] = true
+				}
+			}
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if ok && revealMethods[sel.Sel.Name] {
+				pos := fset.Position(call.Pos())
+				if !justified[pos.Line] {
+					rel, _ := filepath.Rel(root, pos.Filename)
+					t.Errorf("%s:%d: call to %s without %q comment", rel, pos.Line, sel.Sel.Name, "reveal:")
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package bls
+
+import (
+	"crypto/rand"
+	"dfinity/beacon/blscgo"
+	"encoding/binary"
+	"strconv"
+)
+
+// batchScalarBytes -- size of the random scalars, an invalid batch passes with probability 2^-64
+const batchScalarBytes = 8
+
+// SigCheck -- a signature to be verified against a pubkey and message
+type SigCheck struct {
+	Pub Pubkey
+	Msg []byte
+	Sig Signature
+}
+
+// VerifyBatch -- verify many independent signatures at once
+// Returns the indices of all invalid entries in ascending order, nil if all are valid.
+// A batch is checked with a random linear combination of its entries and a single final exponentiation.
+// If it fails, it is split in halves until the invalid entries are isolated.
+func VerifyBatch(checks []SigCheck) []int {
+	return findInvalid(checks, 0)
+}
+
+// findInvalid -- bisect the batch down to its invalid entries
+func findInvalid(checks []SigCheck, offset int) []int {
+	if len(checks) == 0 || batchValid(checks) {
+		return nil
+	}
+	if len(checks) == 1 {
+		return []int{offset}
+	}
+	h := len(checks) / 2
+	return append(findInvalid(checks[:h], offset), findInvalid(checks[h:], offset+h)...)
+}
+
+// batchValid -- check e(sum r_i sig_i, Q) * prod e(-r_i H(msg_i), pub_i) == 1 for random r_i
+// Entries under the same pubkey share one pairing.
+func batchValid(checks []SigCheck) bool {
+	sigVerifyCalls++
+	var ssum *blscgo.Sign
+	hsums := make(map[string]*blscgo.Sign)
+	pks := make(map[string]*blscgo.PublicKey)
+	var order []string
+	for _, c := range checks {
+		sign, err := c.Sig.Sig()
+		if err != nil {
+			return false
+		}
+		r, err := randomScalar()
+		if err != nil {
+			logger.Error("no randomness for batch verification", "err", err)
+			return false
+		}
+		sign.Mul(r)
+		if ssum == nil {
+			ssum = sign
+		} else {
+			ssum.Add(sign)
+		}
+		h := blscgo.HashToSign(string(c.Msg))
+		h.Mul(r)
+		key := c.Pub.String()
+		if hsum, ok := hsums[key]; ok {
+			hsum.Add(h)
+			continue
+		}
+		pk, err := c.Pub.PublicKey()
+		if err != nil {
+			return false
+		}
+		hsums[key], pks[key] = h, pk
+		order = append(order, key)
+	}
+	g1 := make([]blscgo.Sign, len(order)+1)
+	g2 := make([]blscgo.PublicKey, len(order)+1)
+	gen, err := blscgo.GeneratorPublicKey()
+	if err != nil {
+		return false
+	}
+	g1[0], g2[0] = *ssum, *gen
+	for i, key := range order {
+		hsums[key].Neg()
+		g1[i+1], g2[i+1] = *hsums[key], *pks[key]
+	}
+	return blscgo.MultiPairingIsOne(g1, g2)
+}
+
+// randomScalar -- a nonzero random scalar of batchScalarBytes bytes
+func randomScalar() (*blscgo.SecretKey, error) {
+	var b [batchScalarBytes]byte
+	var x uint64
+	for x == 0 {
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		x = binary.BigEndian.Uint64(b[:])
+	}
+	r := new(blscgo.SecretKey)
+	if err := r.SetStr(strconv.FormatUint(x, 10)); err != nil {
+		return nil, ErrDeserialize
+	}
+	return r, nil
+}
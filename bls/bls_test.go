@@ -1,27 +1,41 @@
 package bls
 
-import "testing"
-import "dfinity/beacon/blscgo"
+import (
+	"dfinity/beacon/blscgo"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"strconv"
+	"testing"
+)
 
 func TestComparison(t *testing.T) {
 	t.Log("testComparison")
 	blscgo.Init(blscgo.CurveFp254BNb)
 	b := Decimal2Big("16798108731015832284940804142231733909759579603404752749028378864165570215948")
 	sec := SeckeyFromBigInt(&b)
-	t.Log("sec.Hex: ", sec.Hex())
-	t.Log("sec.String: ", sec.String())
+	t.Log("sec.RevealHex: ", sec.RevealHex())
+	if sec.String() != "<redacted>" || fmt.Sprintf("%x %v %+v %#v", sec, sec, sec, sec) != "<redacted> <redacted> <redacted> <redacted>" {
+		t.Error("Seckey is not redacted when printed")
+	}
 
 	// Add Seckeys
 	sum := AggregateSeckeys([]Seckey{sec, sec})
-	t.Log("sum: ", sum.Hex())
+	t.Log("sum: ", sum.RevealHex())
 
-	sk := sec.SecretKey()
+	sk, err := sec.SecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
 	t.Log("sk = sec.SecretKey(): ", sk.String())
 
 	// Pubkey
 	pk := sk.GetPublicKey()
 	t.Log("pk: ", pk.String())
-	pub := PubkeyFromSeckey(sec)
+	pub, err := PubkeyFromSeckey(sec)
+	if err != nil {
+		t.Fatal(err)
+	}
 	t.Log("pub: ", pub.String())
 	//pub2 := PublicKeyFromSeckey(sec)
 	//t.Log("pub2: ", pub2.String())
@@ -30,14 +44,326 @@ func TestComparison(t *testing.T) {
 	sk.Add(sk)
 	t.Log("sksum: ", sk.String())
 
-	if sk.String() != sum.Hex() {
+	if sk.String() != sum.RevealHex() {
 		t.Error("Mismatch in secret key addition")
 	}
 
 	// Sig
-	sig := Sign(sec, []byte("hi"))
-	asig := AggregateSigs([]Signature{sig, sig})
+	sig, err := Sign(sec, []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	asig, err := AggregateSigs([]Signature{sig, sig})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !VerifyAggregateSig([]Pubkey{pub, pub}, []byte("hi"), asig) {
 		t.Error("Aggregated signature does not verify")
 	}
 }
+
+func TestErrors(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	if _, err := (PubkeyFromString("not a pubkey")).PublicKey(); err != ErrDeserialize {
+		t.Error("Expected ErrDeserialize, got", err)
+	}
+	if VerifySig(PubkeyFromString("not a pubkey"), []byte("hi"), SignatureFromString("not a sig")) {
+		t.Error("Malformed signature verifies")
+	}
+	msec := []Seckey{SeckeyFromInt(1), SeckeyFromInt(2)}
+	pub1, _ := PubkeyFromSeckey(msec[0])
+	pub2, _ := PubkeyFromSeckey(msec[1])
+	vvec := []Pubkey{pub1, pub2}
+	id := IDFromInt64(5)
+	share, err := ShareSeckey(msec, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyShare(vvec, id, share); err != nil {
+		t.Error("Valid share rejected:", err)
+	}
+	if err := VerifyShare(vvec, id, SeckeyFromInt(3)); err != ErrInvalidShare {
+		t.Error("Expected ErrInvalidShare, got", err)
+	}
+	if _, err := RecoverSeckeyByMap(SeckeyMap{}, nil, 2); err != ErrTooFewShares {
+		t.Error("Expected ErrTooFewShares, got", err)
+	}
+}
+
+func TestSeckeyDestroy(t *testing.T) {
+	b := Decimal2Big("1234567890123456789012345678901234567890")
+	sec := SeckeyFromBigInt(&b)
+	cp := sec
+	if !sec.Equal(cp) || sec.Equal(SeckeyFromInt(1)) {
+		t.Error("Equal is wrong")
+	}
+	if len(sec.Reveal()) != SeckeyLength {
+		t.Error("Reveal has wrong length")
+	}
+	sec.Destroy()
+	if !cp.Equal(SeckeyFromInt(0)) {
+		t.Error("Destroy did not wipe the copy of the secret")
+	}
+	if b.String() != "1234567890123456789012345678901234567890" {
+		t.Error("Destroy wiped the big.Int the Seckey was created from")
+	}
+}
+
+func TestMultiSig(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	n := 5
+	pubs := make([]Pubkey, n)
+	msgs := make([][]byte, n)
+	sigs := make([]Signature, n)
+	pops := make([]Pop, n)
+	for i := 0; i < n; i++ {
+		sec := SeckeyFromRand(RandFromBytes([]byte("multisig")).Deri(i))
+		pubs[i], _ = PubkeyFromSeckey(sec)
+		msgs[i] = []byte("message " + strconv.Itoa(i))
+		sigs[i], _ = Sign(sec, msgs[i])
+		pops[i], _ = GeneratePop(sec, pubs[i])
+	}
+	asig, err := AggregateSigs(sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyMultiSig(pubs, msgs, asig) {
+		t.Error("Aggregate multi-message signature does not verify")
+	}
+	msgs[0], msgs[1] = msgs[1], msgs[0]
+	if VerifyMultiSig(pubs, msgs, asig) {
+		t.Error("Aggregate signature verifies with swapped messages")
+	}
+	msgs[0] = msgs[2]
+	if VerifyMultiSig(pubs, msgs, asig) {
+		t.Error("Aggregate signature verifies with duplicate messages")
+	}
+	apop, err := AggregatePops(pops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyAggregatePop(pubs, apop) {
+		t.Error("Aggregate pop does not verify")
+	}
+	if VerifyAggregatePop(pubs[1:], apop) {
+		t.Error("Aggregate pop verifies for a subset of pubkeys")
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	pub, msgs, sigs := benchChain(10)
+	other, _ := PubkeyFromSeckey(SeckeyFromInt(42))
+	checks := make([]SigCheck, len(sigs))
+	for i := range sigs {
+		checks[i] = SigCheck{pub, msgs[i], sigs[i]}
+	}
+	// entries under a second pubkey
+	for i := 0; i < 3; i++ {
+		sig, _ := Sign(SeckeyFromInt(42), msgs[i])
+		checks = append(checks, SigCheck{other, msgs[i], sig})
+	}
+	if bad := VerifyBatch(checks); bad != nil {
+		t.Fatal("Valid batch rejected, invalid entries:", bad)
+	}
+	checks[3].Sig, checks[7].Sig = checks[7].Sig, checks[3].Sig
+	checks[11].Msg = []byte("forged")
+	bad := VerifyBatch(checks)
+	if len(bad) != 3 || bad[0] != 3 || bad[1] != 7 || bad[2] != 11 {
+		t.Error("Expected invalid entries [3 7 11], got", bad)
+	}
+}
+
+func TestLagrangeBasis(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	msec := []Seckey{SeckeyFromInt(11), SeckeyFromInt(22), SeckeyFromInt(33)}
+	msg := []byte("hi")
+	master, _ := Sign(msec[0], msg)
+	secs, sigs := SeckeyMap{}, SignatureMap{}
+	var addrs []common.Address
+	for i := 0; i < 6; i++ {
+		a := common.BigToAddress(big.NewInt(int64(100 - 7*i)))
+		addrs = append(addrs, a)
+		secs[a], _ = ShareSeckeyByAddr(msec, a)
+		sigs[a], _ = Sign(secs[a], msg)
+	}
+	signers, err := SelectAddrs(addrs, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, a := range addrs[3:] {
+		if signers[2-i] != a {
+			t.Fatal("SelectAddrs does not select the smallest IDs in order")
+		}
+	}
+	basis, err := NewLagrangeBasisByAddr(signers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssecs := make([]Seckey, basis.Len())
+	ssigs := make([]Signature, basis.Len())
+	for i, a := range signers {
+		ssecs[i], ssigs[i] = secs[a], sigs[a]
+	}
+	if sec, err := RecoverSeckeyByBasis(ssecs, basis); err != nil || !sec.Equal(msec[0]) {
+		t.Error("RecoverSeckeyByBasis does not recover the master secret", err)
+	}
+	if sig, err := RecoverSignatureByBasis(ssigs, basis); err != nil || sig.String() != master.String() {
+		t.Error("RecoverSignatureByBasis does not recover the master signature", err)
+	}
+	if sig, err := RecoverSignatureByMap(sigs, nil, 3); err != nil || sig.String() != master.String() {
+		t.Error("RecoverSignatureByMap does not recover the master signature", err)
+	}
+	if _, err := RecoverSignatureByBasis(ssigs[:2], basis); err != ErrTooFewShares {
+		t.Error("Expected ErrTooFewShares, got", err)
+	}
+	if _, err := NewLagrangeBasisByAddr([]common.Address{addrs[0], addrs[1], addrs[0]}); err != ErrDuplicateID {
+		t.Error("Expected ErrDuplicateID, got", err)
+	}
+}
+
+func TestVerificationVector(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	// two dealers with polynomials of degree 1
+	msecs := [][]Seckey{{SeckeyFromInt(3), SeckeyFromInt(5)}, {SeckeyFromInt(7), SeckeyFromInt(11)}}
+	vvecs := make([]VerificationVector, len(msecs))
+	for i, msec := range msecs {
+		var err error
+		if vvecs[i], err = VerificationVectorFromSeckeys(msec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vvec, err := CombineVerificationVectors(vvecs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vvec.Threshold() != 2 {
+		t.Error("Wrong threshold", vvec.Threshold())
+	}
+	if pub, _ := PubkeyFromSeckey(SeckeyFromInt(10)); vvec.Pubkey().String() != pub.String() {
+		t.Error("Combined vector does not commit to the sum of the secrets")
+	}
+	id := IDFromInt64(9)
+	s0, _ := ShareSeckey(msecs[0], id)
+	s1, _ := ShareSeckey(msecs[1], id)
+	share := AggregateSeckeys([]Seckey{s0, s1})
+	if err := VerifyShare(vvec, id, share); err != nil {
+		t.Error("Combined share rejected by combined vector:", err)
+	}
+	sig, _ := Sign(share, []byte("hi"))
+	if !vvec.VerifySigShare(id, []byte("hi"), sig) || vvec.VerifySigShare(IDFromInt64(8), []byte("hi"), sig) {
+		t.Error("VerifySigShare is wrong")
+	}
+	dup, err := VerificationVectorFromString(vvec.String())
+	if err != nil || dup.String() != vvec.String() {
+		t.Error("Serialization does not round-trip", err)
+	}
+	if vvec.IsZeroSharing() {
+		t.Error("Vector of a nonzero secret is a zero sharing")
+	}
+	zvec, _ := VerificationVectorFromSeckeys([]Seckey{SeckeyFromInt(0), SeckeyFromInt(13)})
+	if !zvec.IsZeroSharing() {
+		t.Error("Vector of a zero sharing not recognized")
+	}
+	if refreshed, err := CombineVerificationVectors([]VerificationVector{vvec, zvec}); err != nil || refreshed.Pubkey().String() != vvec.Pubkey().String() {
+		t.Error("Adding a zero sharing changes the pubkey", err)
+	}
+	if _, err := CombineVerificationVectors([]VerificationVector{vvec, vvec[:1]}); err != ErrVvecLength {
+		t.Error("Expected ErrVvecLength, got", err)
+	}
+}
+
+func TestIDValidation(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	if err := IDFromIndex(1).Validate(); err != nil {
+		t.Error("Valid ID rejected:", err)
+	}
+	for _, id := range []ID{IDFromInt64(0), IDFromInt64(-1), IDFromBig(&R), IDFromBig(new(big.Int).Add(&R, big.NewInt(1)))} {
+		if err := id.Validate(); err != ErrInvalidID {
+			t.Error("Expected ErrInvalidID for", id, "got", err)
+		}
+	}
+	if err := ValidateIDs([]ID{IDFromIndex(1), IDFromIndex(2), IDFromIndex(1)}); err != ErrDuplicateID {
+		t.Error("Expected ErrDuplicateID, got", err)
+	}
+	// the share of ID 0 is the secret itself, VerifyShare has to refuse it
+	msec := []Seckey{SeckeyFromInt(1), SeckeyFromInt(2)}
+	vvec, _ := VerificationVectorFromSeckeys(msec)
+	if err := VerifyShare(vvec, IDFromInt64(0), msec[0]); err != ErrInvalidID {
+		t.Error("Expected ErrInvalidID, got", err)
+	}
+	// and no share is dealt for it
+	if _, err := ShareSeckey(msec, IDFromInt64(0)); err != ErrInvalidID {
+		t.Error("Expected ErrInvalidID, got", err)
+	}
+}
+
+func TestRecoverByMapWithIDs(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	msec := []Seckey{SeckeyFromInt(11), SeckeyFromInt(22), SeckeyFromInt(33)}
+	msg := []byte("hi")
+	master, _ := Sign(msec[0], msg)
+	// IDs by index, in reverse order of the addresses
+	ids := IDMap{}
+	secs, sigs := SeckeyMap{}, SignatureMap{}
+	for i := 0; i < 5; i++ {
+		a := common.BigToAddress(big.NewInt(int64(100 + i)))
+		ids[a] = IDFromIndex(5 - i)
+		sec, err := ShareSeckey(msec, ids[a])
+		if err != nil {
+			t.Fatal(err)
+		}
+		secs[a] = sec
+		sigs[a], _ = Sign(sec, msg)
+	}
+	if sec, err := RecoverSeckeyByMap(secs, ids, 3); err != nil || !sec.Equal(msec[0]) {
+		t.Error("RecoverSeckeyByMap does not recover the master secret with the given IDs", err)
+	}
+	if sig, err := RecoverSignatureByMap(sigs, ids, 3); err != nil || sig.String() != master.String() {
+		t.Error("RecoverSignatureByMap does not recover the master signature with the given IDs", err)
+	}
+	if sec, err := RecoverSeckeyByMap(secs, nil, 3); err != nil || sec.Equal(msec[0]) {
+		t.Error("Shares by index recovered with IDs by address", err)
+	}
+	delete(ids, common.BigToAddress(big.NewInt(100)))
+	if _, err := RecoverSeckeyByMap(secs, ids, 3); err != ErrInvalidID {
+		t.Error("Expected ErrInvalidID for a share without ID, got", err)
+	}
+}
+
+// benchChain -- a chain of l signatures, each signing its predecessor, with a single key
+func benchChain(l int) (pub Pubkey, msgs [][]byte, sigs []Signature) {
+	sec := SeckeyFromRand(RandFromBytes([]byte("bench")))
+	pub, _ = PubkeyFromSeckey(sec)
+	msgs = make([][]byte, l)
+	sigs = make([]Signature, l)
+	msg := []byte("genesis")
+	for i := range sigs {
+		msgs[i] = msg
+		sigs[i], _ = Sign(sec, msg)
+		msg = sigs[i].Rand().Bytes()
+	}
+	return
+}
+
+func benchmarkVerifyChain(l int, cached bool, b *testing.B) {
+	b.StopTimer()
+	blscgo.Init(blscgo.CurveFp254BNb)
+	pub, msgs, sigs := benchChain(l)
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range sigs {
+			p, s := pub, sigs[i]
+			if !cached {
+				// force a reparse, as stored blocks read from disk would need
+				p, s = PubkeyFromString(pub.String()), SignatureFromString(sigs[i].String())
+			}
+			if !VerifySig(p, msgs[i], s) {
+				b.Fatal("Signature " + strconv.Itoa(i) + " does not verify")
+			}
+		}
+	}
+}
+
+func BenchmarkVerifyChain100Cached(b *testing.B)   { benchmarkVerifyChain(100, true, b) }
+func BenchmarkVerifyChain100Uncached(b *testing.B) { benchmarkVerifyChain(100, false, b) }
@@ -3,8 +3,8 @@ package bls
 import (
 	"dfinity/beacon/blscgo"
 	"github.com/ethereum/go-ethereum/common"
-	"log"
 	"math/big"
+	"sort"
 )
 
 // ID -- id for secret sharing, represented by big.Int
@@ -12,6 +12,9 @@ type ID struct {
 	value big.Int
 }
 
+// IDMap -- the IDs for secret sharing by address, e.g. of the members of a group
+type IDMap map[common.Address]ID
+
 // Setters
 
 // SetBig --
@@ -22,14 +25,89 @@ func (id *ID) SetBig(b *big.Int) {
 // Getters
 
 // CgoID --
-func (id ID) CgoID() (cgoid blscgo.ID) {
-	err := cgoid.SetStr(id.value.String())
-	if err != nil {
-		log.Fatalln("Error in ID conversion to blscgo.")
+func (id ID) CgoID() (cgoid blscgo.ID, err error) {
+	if cgoid.SetStr(id.value.String()) != nil {
+		logger.Error("ID conversion to blscgo failed", "id", id.value.String())
+		err = ErrDeserialize
 	}
 	return
 }
 
+// String -- the ID as decimal integer
+func (id ID) String() string {
+	return id.value.String()
+}
+
+// Equal --
+func (id ID) Equal(other ID) bool {
+	return id.value.Cmp(&other.value) == 0
+}
+
+// Validate -- an ID has to be in the range 1..R-1
+// The share of ID 0 would be the shared secret itself, and IDs outside the range collide with IDs inside it.
+func (id ID) Validate() error {
+	if id.value.Sign() <= 0 || id.value.Cmp(&R) >= 0 {
+		logger.Error("invalid ID", "id", id.value.String())
+		return ErrInvalidID
+	}
+	return nil
+}
+
+// ValidateIDs -- all IDs valid and pairwise distinct, as required for sharing and recovery
+func ValidateIDs(ids []ID) error {
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if err := id.Validate(); err != nil {
+			return err
+		}
+		if seen[id.value.String()] {
+			logger.Error("duplicate ID", "id", id.value.String())
+			return ErrDuplicateID
+		}
+		seen[id.value.String()] = true
+	}
+	return nil
+}
+
+// selectIDs -- the k of the given addresses with the smallest IDs and their IDs, sorted by ID
+// Without a map the IDs are derived from the addresses.
+func (m IDMap) selectIDs(addrs []common.Address, k int) ([]common.Address, []ID, error) {
+	if len(addrs) < k || k <= 0 {
+		return nil, nil, ErrTooFewShares
+	}
+	sorted := append([]common.Address{}, addrs...)
+	ids := make(map[common.Address]ID, len(addrs))
+	for _, a := range sorted {
+		id, ok := m[a]
+		if m == nil {
+			id, ok = IDFromAddress(a), true
+		}
+		if !ok {
+			logger.Error("no ID for share", "addr", a.Hex())
+			return nil, nil, ErrInvalidID
+		}
+		ids[a] = id
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		x, y := ids[sorted[i]], ids[sorted[j]]
+		return x.value.Cmp(&y.value) < 0
+	})
+	sorted = sorted[:k]
+	sel := make([]ID, k)
+	for i, a := range sorted {
+		sel[i] = ids[a]
+	}
+	if err := ValidateIDs(sel); err != nil {
+		return nil, nil, err
+	}
+	return sorted, sel, nil
+}
+
+// scalar -- the ID as element of the scalar field
+func (id ID) scalar() Scalar {
+	return scalarFromBigVartime(&id.value)
+}
+
 // Constructors
 
 // IDFromBig --
@@ -44,6 +122,11 @@ func IDFromInt64(i int64) (id ID) {
 	return
 }
 
+// IDFromIndex -- the ID of the member with the (1-based) index i
+func IDFromIndex(i int) ID {
+	return IDFromInt64(int64(i))
+}
+
 // IDFromAddress --
 func IDFromAddress(addr common.Address) ID {
 	return IDFromBig(addr.Big())
@@ -0,0 +1,138 @@
+package bls
+
+import (
+	"dfinity/beacon/blscgo"
+	"github.com/ethereum/go-ethereum/common"
+	"sort"
+)
+
+// LagrangeBasis -- Lagrange coefficients for interpolation at 0, precomputed for a fixed set of IDs
+// The coefficients depend only on the (public) IDs, so one basis can be reused for every
+// recovery from the same signer set.
+type LagrangeBasis struct {
+	ids    []ID
+	coeffs []Scalar
+}
+
+// Constructors
+
+// NewLagrangeBasis -- precompute the coefficients for the given IDs, which have to be valid and distinct
+func NewLagrangeBasis(ids []ID) (b LagrangeBasis, err error) {
+	if len(ids) == 0 {
+		return b, ErrTooFewShares
+	}
+	if err = ValidateIDs(ids); err != nil {
+		return
+	}
+	x := make([]Scalar, len(ids))
+	for i := range ids {
+		x[i] = ids[i].scalar()
+	}
+	b.ids = append([]ID{}, ids...)
+	b.coeffs = lagrangeCoeffs(x)
+	return
+}
+
+// NewLagrangeBasisByAddr -- wrapper around NewLagrangeBasis for member addresses
+func NewLagrangeBasisByAddr(addrs []common.Address) (LagrangeBasis, error) {
+	ids := make([]ID, len(addrs))
+	for i, a := range addrs {
+		ids[i] = IDFromAddress(a)
+	}
+	return NewLagrangeBasis(ids)
+}
+
+// Getters
+
+// IDs -- the IDs of the basis, in the order in which shares have to be supplied
+func (b LagrangeBasis) IDs() []ID {
+	return append([]ID{}, b.ids...)
+}
+
+// Len -- number of IDs in the basis
+func (b LagrangeBasis) Len() int {
+	return len(b.ids)
+}
+
+// Selection
+
+// SelectAddrs -- the k addresses with the smallest IDs, sorted by ID
+// This is the deterministic choice of shares made by RecoverSeckeyByMap and RecoverSignatureByMap for IDs by
+// address, and for IDs by index, which have the same order.
+func SelectAddrs(addrs []common.Address, k int) ([]common.Address, error) {
+	if len(addrs) < k {
+		return nil, ErrTooFewShares
+	}
+	sorted := append([]common.Address{}, addrs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Big().Cmp(sorted[j].Big()) < 0
+	})
+	return sorted[:k], nil
+}
+
+// Recovery
+
+// RecoverSeckeyByBasis -- Recover master from shares given in the order of b.IDs()
+func RecoverSeckeyByBasis(secs []Seckey, b LagrangeBasis) (sec Seckey, err error) {
+	if len(secs) != b.Len() || len(secs) == 0 {
+		return sec, ErrTooFewShares
+	}
+	secRecoverCalls++
+	secRecoverLen += len(secs)
+	sec.secret = new(Scalar)
+	var t Scalar
+	for i := range secs {
+		t.Mul(&b.coeffs[i], secs[i].scalar())
+		sec.secret.Add(sec.secret, &t)
+	}
+	t.Wipe()
+	return
+}
+
+// RecoverSignatureByBasis -- Recover master from shares given in the order of b.IDs()
+func RecoverSignatureByBasis(sigs []Signature, b LagrangeBasis) (sig Signature, err error) {
+	if len(sigs) != b.Len() || len(sigs) == 0 {
+		return sig, ErrTooFewShares
+	}
+	sigRecoverCalls++
+	sigRecoverLen += len(sigs)
+	var sum *blscgo.Sign
+	for i, s := range sigs {
+		sign, err := s.Sig()
+		if err != nil {
+			return sig, err
+		}
+		coeff := b.coeffs[i]
+		sk, err := Seckey{&coeff}.SecretKey()
+		if err != nil {
+			return sig, err
+		}
+		sign.Mul(sk)
+		sk.Clear()
+		if sum == nil {
+			sum = sign
+		} else {
+			sum.Add(sign)
+		}
+	}
+	return signatureFromCgo(sum), nil
+}
+
+// lagrangeCoeffs -- delta_i = prod_{j != i} x_j / (x_j - x_i)
+func lagrangeCoeffs(x []Scalar) []Scalar {
+	coeffs := make([]Scalar, len(x))
+	for i := range x {
+		num, den := ScalarFromUint64(1), ScalarFromUint64(1)
+		var diff Scalar
+		for j := range x {
+			if j != i {
+				num.Mul(&num, &x[j])
+				diff.Sub(&x[j], &x[i])
+				den.Mul(&den, &diff)
+			}
+		}
+		den.Inverse(&den)
+		coeffs[i].Mul(&num, &den)
+	}
+	return coeffs
+}
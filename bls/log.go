@@ -1,6 +1,42 @@
 package bls
 
-import "fmt"
+import (
+	dfn "dfinity/beacon/common"
+	"errors"
+	"fmt"
+)
+
+// Errors
+
+// ErrDeserialize -- a value could not be converted to or from its blscgo representation
+var ErrDeserialize = errors.New("bls: cannot deserialize value")
+
+// ErrInvalidShare -- a secret share does not match the committed verification vector
+var ErrInvalidShare = errors.New("bls: secret share does not match verification vector")
+
+// ErrTooFewShares -- less shares than the threshold were supplied for recovery
+var ErrTooFewShares = errors.New("bls: not enough shares for recovery")
+
+// ErrInvalidID -- an ID is zero or out of range for secret sharing
+var ErrInvalidID = errors.New("bls: invalid ID")
+
+// ErrDuplicateID -- the same ID occurs twice in a set of signers
+var ErrDuplicateID = errors.New("bls: duplicate ID")
+
+// ErrVvecLength -- verification vectors of different length were combined
+var ErrVvecLength = errors.New("bls: verification vectors differ in length")
+
+// ErrEmpty -- an aggregation was called on an empty list
+var ErrEmpty = errors.New("bls: empty list")
+
+// Logging
+
+var logger dfn.Logger = dfn.NopLogger{}
+
+// SetLogger -- set the logger used by the package (default discards everything)
+func SetLogger(l dfn.Logger) {
+	logger = l
+}
 
 // PrintCtrs -- print counters counting various operations
 func PrintCtrs() {
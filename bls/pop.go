@@ -8,9 +8,10 @@ type Pop Signature
 // Proof-of-Possesion
 
 // GeneratePop --
-func GeneratePop(sec Seckey, pub Pubkey) Pop {
+func GeneratePop(sec Seckey, pub Pubkey) (Pop, error) {
 	tmp := &pub
-	return Pop(Sign(sec, []byte(tmp.String())))
+	sig, err := Sign(sec, []byte(tmp.String()))
+	return Pop(sig), err
 }
 
 // Verification
@@ -19,3 +20,24 @@ func GeneratePop(sec Seckey, pub Pubkey) Pop {
 func VerifyPop(pub Pubkey, pop Pop) bool {
 	return VerifySig(pub, []byte(pub.String()), Signature(pop))
 }
+
+// Aggregation
+
+// AggregatePops -- compress many proofs-of-possession into one
+func AggregatePops(pops []Pop) (Pop, error) {
+	sigs := make([]Signature, len(pops))
+	for i, pop := range pops {
+		sigs[i] = Signature(pop)
+	}
+	asig, err := AggregateSigs(sigs)
+	return Pop(asig), err
+}
+
+// VerifyAggregatePop -- verify an aggregate of the proofs-of-possession of all pubs
+func VerifyAggregatePop(pubs []Pubkey, apop Pop) bool {
+	msgs := make([][]byte, len(pubs))
+	for i, pub := range pubs {
+		msgs[i] = []byte(pub.String())
+	}
+	return VerifyMultiSig(pubs, msgs, Signature(apop))
+}
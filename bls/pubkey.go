@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"log"
+	"sync"
 )
 
 /// Crypto
@@ -22,6 +22,15 @@ func PubkeyCtrs() string {
 // Pubkey -
 type Pubkey struct {
 	value []byte
+	// the deserialized point, shared by all copies of the Pubkey
+	point *pubkeyPoint
+}
+
+// pubkeyPoint -- lazily deserialized blscgo point of a Pubkey
+type pubkeyPoint struct {
+	once sync.Once
+	pk   *blscgo.PublicKey
+	err  error
 }
 
 // PubkeyMap --
@@ -50,65 +59,164 @@ func (pub Pubkey) String() string {
 	//	return fmt.Sprintf("%x", pub.Address())
 }
 
-// PublicKey --
-func (pub Pubkey) PublicKey() (pk *blscgo.PublicKey) {
-	pk = new(blscgo.PublicKey)
-	err := pk.SetStr(pub.String())
-	if err != nil {
-		log.Fatalln("Error in PublicKey conversion to blscgo.")
+// PublicKey -- the blscgo point, deserialized on first use
+// The caller owns the returned copy and may modify it.
+func (pub Pubkey) PublicKey() (*blscgo.PublicKey, error) {
+	p := pub.point
+	if p == nil {
+		p = &pubkeyPoint{}
 	}
+	p.once.Do(func() {
+		p.pk = new(blscgo.PublicKey)
+		if p.pk.SetStr(pub.String()) != nil {
+			logger.Error("PublicKey conversion to blscgo failed", "pub", pub.String())
+			p.pk, p.err = nil, ErrDeserialize
+		}
+	})
+	if p.err != nil {
+		return nil, p.err
+	}
+	pk := *p.pk
+	return &pk, nil
+}
+
+// Constructors
+
+// pubkeyFromCgo -- wrap a blscgo point, the Pubkey takes ownership of pk
+func pubkeyFromCgo(pk *blscgo.PublicKey) (pub Pubkey) {
+	pub.value = []byte(pk.String())
+	pub.point = &pubkeyPoint{}
+	pub.point.once.Do(func() { pub.point.pk = pk })
 	return
 }
 
+// PubkeyFromString -- a Pubkey from its string representation, deserialized on first use
+func PubkeyFromString(s string) Pubkey {
+	return Pubkey{value: []byte(s), point: &pubkeyPoint{}}
+}
+
 // Generation
 
 // PubkeyFromSeckey -- derive the pubkey from seckey
-func PubkeyFromSeckey(sec Seckey) (pub Pubkey) {
+func PubkeyFromSeckey(sec Seckey) (pub Pubkey, err error) {
 	//	pubkey_ctr++
 	pubGenCalls++
 	// Convert via blscgo
-	pk := sec.SecretKey().GetPublicKey()
-	pub.value = []byte(pk.String())
+	sk, err := sec.SecretKey()
+	if err != nil {
+		return
+	}
+	defer sk.Clear()
+	pub = pubkeyFromCgo(sk.GetPublicKey())
 	return
 }
 
 // AggregatePubkeys -- aggregate multiple into one by summing up
-func AggregatePubkeys(pubs []Pubkey) (pub Pubkey) {
+func AggregatePubkeys(pubs []Pubkey) (pub Pubkey, err error) {
 	pubAggCalls++
 	pubAggLen += len(pubs)
 	// initialize sum to zero
 	var s blscgo.SecretKey
-	err := s.SetStr("0")
-	if err != nil {
-		log.Fatalln("Error in PublicKey conversion to blscgo.")
+	if s.SetStr("0") != nil {
+		logger.Error("SecretKey conversion to blscgo failed")
+		return pub, ErrDeserialize
 	}
 	sum := s.GetPublicKey()
 	// sum it up
 	for _, p := range pubs {
-		sum.Add(p.PublicKey())
+		pk, err := p.PublicKey()
+		if err != nil {
+			return pub, err
+		}
+		sum.Add(pk)
 	}
 	// convert back from blscgo
-	pub.value = []byte(sum.String())
+	pub = pubkeyFromCgo(sum)
 	return
 }
 
 // SharePubkey -- Derive shares from master through polynomial substitution
-func SharePubkey(mpub []Pubkey, id ID) (pub Pubkey) {
+func SharePubkey(mpub []Pubkey, id ID) (pub Pubkey, err error) {
 	pubShareCalls++
 	pubShareLen += len(mpub)
 
 	// convert to blscgo master
 	mpk := make([]blscgo.PublicKey, len(mpub))
 	for i, p := range mpub {
-		mpk[i] = *(p.PublicKey())
+		pk, err := p.PublicKey()
+		if err != nil {
+			return pub, err
+		}
+		mpk[i] = *pk
 	}
 
 	// derive gshare
-	var pk blscgo.PublicKey
-	cgoid := id.CgoID()
+	pk := new(blscgo.PublicKey)
+	cgoid, err := id.CgoID()
+	if err != nil {
+		return
+	}
 	pk.Set(mpk, &cgoid)
 
 	// convert back from blscgo
-	pub.value = []byte(pk.String())
+	pub = pubkeyFromCgo(pk)
 	return
 }
+
+// RecoverPubkey -- Recover the pubkey at 0 from pubkeys at the given IDs through Lagrange interpolation
+func RecoverPubkey(pubs []Pubkey, ids []ID) (pub Pubkey, err error) {
+	if len(pubs) == 0 || len(pubs) != len(ids) {
+		return pub, ErrTooFewShares
+	}
+	pkVec := make([]blscgo.PublicKey, len(pubs))
+	for i, p := range pubs {
+		pk, err := p.PublicKey()
+		if err != nil {
+			return pub, err
+		}
+		pkVec[i] = *pk
+	}
+	idVec := make([]blscgo.ID, len(ids))
+	for i, id := range ids {
+		if idVec[i], err = id.CgoID(); err != nil {
+			return
+		}
+	}
+	pk := new(blscgo.PublicKey)
+	pk.Recover(pkVec, idVec)
+	return pubkeyFromCgo(pk), nil
+}
+
+// MulPubkey -- the point of pub multiplied by the scalar sec, e.g. a Diffie-Hellman value
+func MulPubkey(pub Pubkey, sec Seckey) (Pubkey, error) {
+	pk, err := pub.PublicKey()
+	if err != nil {
+		return Pubkey{}, err
+	}
+	sk, err := sec.SecretKey()
+	if err != nil {
+		return Pubkey{}, err
+	}
+	defer sk.Clear()
+	pk.Mul(sk)
+	return pubkeyFromCgo(pk), nil
+}
+
+// VerifyShare -- check a secret share against the verification vector of the dealer
+func VerifyShare(vvec VerificationVector, id ID, share Seckey) error {
+	if err := id.Validate(); err != nil {
+		return err
+	}
+	lhs, err := vvec.PubkeyAt(id)
+	if err != nil {
+		return err
+	}
+	rhs, err := PubkeyFromSeckey(share)
+	if err != nil {
+		return err
+	}
+	if lhs.String() != rhs.String() {
+		return ErrInvalidShare
+	}
+	return nil
+}
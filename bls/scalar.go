@@ -0,0 +1,210 @@
+package bls
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// Scalar -- element of the field of integers modulo R with fixed width
+// The limbs hold the canonical value (< R) as little-endian 64-bit words.
+// Add, Sub, Mul and Inverse run in time independent of the values.
+type Scalar [4]uint64
+
+// ScalarLength -- length of the big-endian encoding of a Scalar
+const ScalarLength = 32
+
+// Constants of the Montgomery arithmetic, derived from R
+var (
+	scalarR   Scalar // R
+	scalarR2  Scalar // 2^512 mod R
+	scalarOne Scalar // 1
+	scalarRm2 Scalar // R-2, exponent for inversion
+	scalarInv uint64 // -R^-1 mod 2^64
+)
+
+func init() {
+	scalarR = scalarLoad(R.Bytes())
+	r2 := new(big.Int).Lsh(big.NewInt(1), 512)
+	scalarR2 = scalarLoad(r2.Mod(r2, &R).Bytes())
+	scalarOne = Scalar{1}
+	scalarRm2 = scalarLoad(new(big.Int).Sub(&R, big.NewInt(2)).Bytes())
+	m := new(big.Int).Lsh(big.NewInt(1), 64)
+	inv := new(big.Int).ModInverse(new(big.Int).Mod(&R, m), m)
+	scalarInv = new(big.Int).Sub(m, inv).Uint64()
+}
+
+// Constructors
+
+// ScalarFromBytes -- big-endian bytes (at most ScalarLength) reduced modulo R
+func ScalarFromBytes(b []byte) (s Scalar) {
+	x := scalarLoad(b)
+	// x * 2^256 / 2^256 reduces any x < 2^256
+	s = montMul(&x, &scalarR2)
+	s = montMul(&s, &scalarOne)
+	x.Wipe()
+	return
+}
+
+// ScalarFromUint64 --
+func ScalarFromUint64(i uint64) Scalar {
+	return Scalar{i}
+}
+
+// scalarFromBigVartime -- for public values only, b has to be non-negative
+func scalarFromBigVartime(b *big.Int) Scalar {
+	if b.Cmp(&R) >= 0 {
+		b = new(big.Int).Mod(b, &R)
+	}
+	return scalarLoad(b.Bytes())
+}
+
+// scalarLoad -- the last ScalarLength big-endian bytes of b as limbs, without reduction
+func scalarLoad(b []byte) (x Scalar) {
+	n := min(len(b), ScalarLength)
+	for i, v := range b[len(b)-n:] {
+		pos := n - 1 - i
+		x[pos/8] |= uint64(v) << (8 * uint(pos%8))
+	}
+	return
+}
+
+// Getters
+
+// Bytes -- big-endian encoding of length ScalarLength
+func (s *Scalar) Bytes() []byte {
+	b := make([]byte, ScalarLength)
+	for i := range b {
+		pos := ScalarLength - 1 - i
+		b[i] = byte(s[pos/8] >> (8 * uint(pos%8)))
+	}
+	return b
+}
+
+// Wipe -- overwrite with zeros
+func (s *Scalar) Wipe() {
+	for i := range s {
+		s[i] = 0
+	}
+}
+
+// Arithmetic
+
+// Add -- z = x + y mod R
+func (z *Scalar) Add(x, y *Scalar) *Scalar {
+	var c uint64
+	var t Scalar
+	for i := range t {
+		t[i], c = bits.Add64(x[i], y[i], c)
+	}
+	*z = t.reduceOnce(c)
+	return z
+}
+
+// Sub -- z = x - y mod R
+func (z *Scalar) Sub(x, y *Scalar) *Scalar {
+	var b uint64
+	var t Scalar
+	for i := range t {
+		t[i], b = bits.Sub64(x[i], y[i], b)
+	}
+	// add R back if there was a borrow
+	mask := -b
+	var c uint64
+	for i := range t {
+		t[i], c = bits.Add64(t[i], scalarR[i]&mask, c)
+	}
+	*z = t
+	return z
+}
+
+// Mul -- z = x * y mod R
+func (z *Scalar) Mul(x, y *Scalar) *Scalar {
+	t := montMul(x, y)
+	*z = montMul(&t, &scalarR2)
+	return z
+}
+
+// Inverse -- z = x^-1 mod R by Fermat's little theorem, zero for zero
+func (z *Scalar) Inverse(x *Scalar) *Scalar {
+	// work in Montgomery form, the exponent R-2 is public
+	xm := montMul(x, &scalarR2)
+	acc := montMul(&scalarOne, &scalarR2)
+	for i := 8*ScalarLength - 1; i >= 0; i-- {
+		acc = montMul(&acc, &acc)
+		if (scalarRm2[i/64]>>uint(i%64))&1 == 1 {
+			acc = montMul(&acc, &xm)
+		}
+	}
+	*z = montMul(&acc, &scalarOne)
+	xm.Wipe()
+	acc.Wipe()
+	return z
+}
+
+// Equal -- constant-time comparison
+func (s *Scalar) Equal(t *Scalar) bool {
+	var d uint64
+	for i := range s {
+		d |= s[i] ^ t[i]
+	}
+	return d == 0
+}
+
+// reduceOnce -- subtract R if the value (with carry c into bit 256) is at least R
+func (s Scalar) reduceOnce(c uint64) Scalar {
+	var d Scalar
+	var b uint64
+	for i := range d {
+		d[i], b = bits.Sub64(s[i], scalarR[i], b)
+	}
+	// take d if there was a carry or no borrow
+	mask := -(c | (b ^ 1))
+	for i := range s {
+		s[i] = (d[i] & mask) | (s[i] &^ mask)
+	}
+	return s
+}
+
+// montMul -- x * y * 2^-256 mod R (CIOS Montgomery multiplication)
+// Requires x * y < R * 2^256, which holds if either factor is below R.
+func montMul(x, y *Scalar) Scalar {
+	var t [6]uint64
+	for i := 0; i < 4; i++ {
+		// t += x * y[i]
+		var c, c1, hi, lo uint64
+		for j := 0; j < 4; j++ {
+			hi, lo = bits.Mul64(x[j], y[i])
+			lo, c1 = bits.Add64(lo, t[j], 0)
+			hi += c1
+			lo, c1 = bits.Add64(lo, c, 0)
+			hi += c1
+			t[j], c = lo, hi
+		}
+		t[4], c1 = bits.Add64(t[4], c, 0)
+		t[5] = c1
+		// t = (t + m * R) / 2^64 with m chosen to clear the lowest word
+		m := t[0] * scalarInv
+		hi, lo = bits.Mul64(m, scalarR[0])
+		_, c1 = bits.Add64(lo, t[0], 0)
+		c = hi + c1
+		for j := 1; j < 4; j++ {
+			hi, lo = bits.Mul64(m, scalarR[j])
+			lo, c1 = bits.Add64(lo, t[j], 0)
+			hi += c1
+			lo, c1 = bits.Add64(lo, c, 0)
+			hi += c1
+			t[j-1], c = lo, hi
+		}
+		t[3], c1 = bits.Add64(t[4], c, 0)
+		t[4] = t[5] + c1
+	}
+	return Scalar{t[0], t[1], t[2], t[3]}.reduceOnce(t[4])
+}
+
+// min --
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+package bls
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func randScalarBig(t *testing.T) *big.Int {
+	b, err := rand.Int(rand.Reader, &R)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func scalarBig(s *Scalar) *big.Int {
+	return new(big.Int).SetBytes(s.Bytes())
+}
+
+func TestScalarArith(t *testing.T) {
+	edge := []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(&R, big.NewInt(1))}
+	for i := 0; i < 200; i++ {
+		a, b := randScalarBig(t), randScalarBig(t)
+		if i < len(edge)*len(edge) {
+			a, b = edge[i/len(edge)], edge[i%len(edge)]
+		}
+		x, y := scalarFromBigVartime(a), scalarFromBigVartime(b)
+		var z Scalar
+		if want := new(big.Int).Mod(new(big.Int).Add(a, b), &R); scalarBig(z.Add(&x, &y)).Cmp(want) != 0 {
+			t.Fatalf("Add(%x, %x) = %x, want %x", a, b, scalarBig(&z), want)
+		}
+		if want := new(big.Int).Mod(new(big.Int).Sub(a, b), &R); scalarBig(z.Sub(&x, &y)).Cmp(want) != 0 {
+			t.Fatalf("Sub(%x, %x) = %x, want %x", a, b, scalarBig(&z), want)
+		}
+		if want := new(big.Int).Mod(new(big.Int).Mul(a, b), &R); scalarBig(z.Mul(&x, &y)).Cmp(want) != 0 {
+			t.Fatalf("Mul(%x, %x) = %x, want %x", a, b, scalarBig(&z), want)
+		}
+		if a.Sign() != 0 {
+			if want := new(big.Int).ModInverse(a, &R); scalarBig(z.Inverse(&x)).Cmp(want) != 0 {
+				t.Fatalf("Inverse(%x) = %x, want %x", a, scalarBig(&z), want)
+			}
+		}
+	}
+}
+
+func TestScalarFromBytes(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		b := make([]byte, ScalarLength)
+		rand.Read(b)
+		b[0] |= 0xf0 // above R
+		s := ScalarFromBytes(b)
+		if want := new(big.Int).Mod(new(big.Int).SetBytes(b), &R); scalarBig(&s).Cmp(want) != 0 {
+			t.Fatalf("ScalarFromBytes(%x) = %x, want %x", b, scalarBig(&s), want)
+		}
+	}
+}
+
+func TestShareRecover(t *testing.T) {
+	k := 5
+	msec := make([]Seckey, k)
+	for i := range msec {
+		msec[i] = SeckeyFromBigInt(randScalarBig(t))
+	}
+	ids := make([]ID, k)
+	secs := make([]Seckey, k)
+	for i := range ids {
+		ids[i] = IDFromInt64(int64(10 + i))
+		var err error
+		if secs[i], err = ShareSeckey(msec, ids[i]); err != nil {
+			t.Fatal(err)
+		}
+		// reference evaluation of the polynomial
+		x := big.NewInt(int64(10 + i))
+		want := new(big.Int)
+		for j := k - 1; j >= 0; j-- {
+			c := new(big.Int).SetBytes(msec[j].Reveal()) // reveal: test only
+			want.Mul(want, x).Add(want, c).Mod(want, &R)
+		}
+		if new(big.Int).SetBytes(secs[i].Reveal()).Cmp(want) != 0 { // reveal: test only
+			t.Fatal("ShareSeckey differs from reference evaluation")
+		}
+	}
+	if !RecoverSeckey(secs, ids).Equal(msec[0]) {
+		t.Error("RecoverSeckey does not recover the master secret")
+	}
+}
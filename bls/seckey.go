@@ -4,7 +4,7 @@ import (
 	"dfinity/beacon/blscgo"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
-	"log"
+	"io"
 	"math/big"
 )
 
@@ -23,46 +23,105 @@ var R = Decimal2Big("16798108731015832284940804142231733909759579603404752749028
 
 // types
 
-// Seckey -- represented by a big.Int modulo R
+// Seckey -- represented by a Scalar modulo R, all arithmetic on it is constant-time
+// The value is opaque: all fmt verbs print a placeholder, it can only be read through Reveal.
+// Copies of a Seckey share the same memory, Destroy wipes all of them.
 type Seckey struct {
-	secret *big.Int
+	secret *Scalar
 }
 
 // SeckeyMap -- a map from addresses to Seckey
 type SeckeyMap map[common.Address]Seckey
 
-// Getters
+// SeckeyLength -- length of the big-endian encoding returned by Reveal
+const SeckeyLength = ScalarLength
 
-// Bytes --
-func (sec Seckey) Bytes() []byte {
-	// big endian
+// redacted -- printed instead of the value of a Seckey
+const redacted = "<redacted>"
+
+// Formatting
+
+// String -- never the secret, see Reveal
+func (sec Seckey) String() string {
+	return redacted
+}
+
+// GoString --
+func (sec Seckey) GoString() string {
+	return "bls.Seckey{" + redacted + "}"
+}
+
+// Format -- print the placeholder for every verb
+func (sec Seckey) Format(f fmt.State, verb rune) {
+	io.WriteString(f, redacted)
+}
+
+// Export
+
+// Reveal -- the secret as big-endian bytes of length SeckeyLength
+// Every call site has to carry a "reveal:" comment stating why the secret leaves the Seckey (see audit_test.go).
+func (sec Seckey) Reveal() []byte {
+	if sec.secret == nil {
+		return make([]byte, SeckeyLength)
+	}
 	return sec.secret.Bytes()
 }
 
-// String --
-func (sec Seckey) String() string {
-	// big endian
-	return sec.secret.String()
+// RevealHex -- the secret as 0x-prefixed hex string without leading zeros
+// Every call site has to carry a "reveal:" comment, see Reveal.
+func (sec Seckey) RevealHex() string {
+	b := sec.Reveal() // reveal: formatted for export by the caller
+	defer wipeBytes(b)
+	return fmt.Sprintf("0x%x", new(big.Int).SetBytes(b))
 }
 
-// BigInt --
-func (sec Seckey) BigInt() *big.Int {
+// Equal -- constant-time comparison of two secrets
+func (sec Seckey) Equal(other Seckey) bool {
+	return sec.scalar().Equal(other.scalar())
+}
+
+// Destroy -- overwrite the secret with zeros, in this and all copies of the Seckey
+func (sec Seckey) Destroy() {
+	if sec.secret != nil {
+		sec.secret.Wipe()
+	}
+}
+
+// scalar -- the secret, zero for the zero value of Seckey
+func (sec Seckey) scalar() *Scalar {
+	if sec.secret == nil {
+		return new(Scalar)
+	}
 	return sec.secret
 }
 
-// Hex --
-func (sec Seckey) Hex() string {
-	return fmt.Sprintf("0x%x", sec.secret)
+// wipeBytes --
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
 }
 
 // SecretKey -- convert the Seckey to blscgo.SecretKey
-func (sec Seckey) SecretKey() (sk *blscgo.SecretKey) {
-	sk = new(blscgo.SecretKey)
-	err := sk.SetStr(sec.String())
-	if err != nil {
-		log.Fatalln("Error in SecretKey conversion to blscgo.")
+// The caller should Clear the result when done with it.
+func (sec Seckey) SecretKey() (*blscgo.SecretKey, error) {
+	n := blscgo.GetOpUnitSize()
+	s := sec.scalar()
+	if n < len(s) && s[len(s)-1] != 0 {
+		logger.Error("SecretKey conversion to blscgo failed")
+		return nil, ErrDeserialize
 	}
-	return
+	// little-endian 64-bit words, the layout expected by SetArray
+	words := make([]uint64, n)
+	defer func() {
+		for i := range words {
+			words[i] = 0
+		}
+	}()
+	copy(words, s[:])
+	sk := new(blscgo.SecretKey)
+	sk.SetArray(words)
+	return sk, nil
 }
 
 // Constructors
@@ -75,8 +134,8 @@ func SeckeyFromBytes(b []byte) (sec Seckey) {
 	if len(b) > 31 {
 		b = b[:31]
 	}
-	i := Bytes2Big(b)
-	sec.secret = &i
+	s := ScalarFromBytes(b)
+	sec.secret = &s
 	return
 }
 
@@ -85,51 +144,56 @@ func SeckeyFromRand(seed Rand) Seckey {
 	return SeckeyFromBytes(seed.Bytes())
 }
 
-// SeckeyFromBigInt --
+// SeckeyFromBigInt -- b reduced modulo R, the Seckey does not keep a reference to b
 func SeckeyFromBigInt(b *big.Int) (sec Seckey) {
-	sec.secret = b
+	s := scalarFromBigVartime(new(big.Int).Mod(b, &R))
+	sec.secret = &s
 	return
 }
 
 // SeckeyFromInt --
 func SeckeyFromInt(i int64) (sec Seckey) {
-	sec.secret = big.NewInt(i)
-	return
+	return SeckeyFromBigInt(big.NewInt(i))
 }
 
 // AggregateSeckeys -- Aggregate multiple seckeys into one by summing up
 func AggregateSeckeys(secs []Seckey) (sec Seckey) {
 	secAggCalls++
 	secAggLen += len(secs)
-	sec.secret = big.NewInt(0)
+	sec.secret = new(Scalar)
 	for _, s := range secs {
-		sec.secret.Add(sec.secret, s.secret)
+		sec.secret.Add(sec.secret, s.scalar())
 	}
-	sec.secret.Mod(sec.secret, &R)
 	return
 }
 
 // ShareSeckey -- Derive shares from master through polynomial substitution
-func ShareSeckey(msec []Seckey, id ID) (sec Seckey) {
+// The id has to be valid (see ID.Validate), the share of ID 0 would be the master secret itself.
+func ShareSeckey(msec []Seckey, id ID) (sec Seckey, err error) {
+	if len(msec) == 0 {
+		return sec, ErrEmpty
+	}
+	if err = id.Validate(); err != nil {
+		return
+	}
 	secShareCalls++
 	secShareLen += len(msec)
-	sec.secret = big.NewInt(0)
+	x := id.scalar()
 	// degree of polynomial, need k >= 1, i.e. len(msec) >= 2
 	k := len(msec) - 1
 	// msec = c_0, c_1, ..., c_k
-	// evaluate polynomial f(x) with coefficients c0, ..., ck
-	sec.secret.Set(msec[k].secret)
+	// evaluate polynomial f(x) with coefficients c0, ..., ck (Horner's scheme)
+	s := *msec[k].scalar()
 	for j := k - 1; j >= 0; j-- {
-		sec.secret.Mul(sec.secret, &id.value)
-		//sec.secret.Mod(&sec.secret, &R)
-		sec.secret.Add(sec.secret, msec[j].secret)
-		sec.secret.Mod(sec.secret, &R)
+		s.Mul(&s, &x)
+		s.Add(&s, msec[j].scalar())
 	}
+	sec.secret = &s
 	return
 }
 
 // ShareSeckeyByAddr -- wrapper around sharing by ID
-func ShareSeckeyByAddr(msec []Seckey, addr common.Address) (sec Seckey) {
+func ShareSeckeyByAddr(msec []Seckey, addr common.Address) (Seckey, error) {
 	return ShareSeckey(msec, IDFromAddress(addr))
 }
 
@@ -137,46 +201,36 @@ func ShareSeckeyByAddr(msec []Seckey, addr common.Address) (sec Seckey) {
 func RecoverSeckey(secs []Seckey, ids []ID) (sec Seckey) {
 	secRecoverCalls++
 	secRecoverLen += len(secs)
-	sec.secret = big.NewInt(0)
-	k := len(secs)
+	sec.secret = new(Scalar)
+	x := make([]Scalar, len(ids))
+	for i := range x {
+		x[i] = ids[i].scalar()
+	}
 	// need len(ids) = k > 0
-	for i := 0; i < k; i++ {
-		// compute delta_i depending on ids only
-		var delta, num, den, diff *big.Int = big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(0)
-		for j := 0; j < k; j++ {
-			if j != i {
-				num.Mul(num, &ids[j].value)
-				num.Mod(num, &R)
-				diff.Sub(&ids[j].value, &ids[i].value)
-				den.Mul(den, diff)
-				den.Mod(den, &R)
-			}
-		}
-		// delta = num / den
-		den.ModInverse(den, &R)
-		delta.Mul(num, den)
-		delta.Mod(delta, &R)
+	var t Scalar
+	for i, delta := range lagrangeCoeffs(x) {
 		// apply delta to secs[i]
-		delta.Mul(delta, secs[i].secret)
-		// skip reducing delta modulo R here
-		sec.secret.Add(sec.secret, delta)
-		sec.secret.Mod(sec.secret, &R)
+		t.Mul(&delta, secs[i].scalar())
+		sec.secret.Add(sec.secret, &t)
 	}
+	t.Wipe()
 	return
 }
 
-// RecoverSeckeyByMap --
-func RecoverSeckeyByMap(m SeckeyMap, k int) (sec Seckey) {
-	ids := make([]ID, k)
+// RecoverSeckeyByMap -- recover from the k shares with the smallest IDs, ids maps the share holders to their IDs
+// With a nil ids, every holder has the ID of its address (IDFromAddress).
+func RecoverSeckeyByMap(m SeckeyMap, ids IDMap, k int) (sec Seckey, err error) {
+	addrs := make([]common.Address, 0, len(m))
+	for a := range m {
+		addrs = append(addrs, a)
+	}
+	addrs, sel, err := ids.selectIDs(addrs, k)
+	if err != nil {
+		return
+	}
 	secs := make([]Seckey, k)
-	i := 0
-	for a, s := range m {
-		ids[i] = IDFromAddress(a)
-		secs[i] = s
-		i++
-		if i >= k {
-			break
-		}
+	for i, a := range addrs {
+		secs[i] = m[a]
 	}
-	return RecoverSeckey(secs, ids)
+	return RecoverSeckey(secs, sel), nil
 }
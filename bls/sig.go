@@ -4,7 +4,7 @@ import (
 	"dfinity/beacon/blscgo"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
-	"log"
+	"sync"
 )
 
 // Debugging counters
@@ -20,6 +20,15 @@ func SignatureCtrs() string {
 // Signature --
 type Signature struct {
 	value []byte
+	// the deserialized point, shared by all copies of the Signature
+	point *signPoint
+}
+
+// signPoint -- lazily deserialized blscgo point of a Signature
+type signPoint struct {
+	once sync.Once
+	sign *blscgo.Sign
+	err  error
 }
 
 // SignatureMap --
@@ -39,100 +48,206 @@ func (sig Signature) String() string {
 
 // Signing
 
-// Sig -- convert Signature to blscgo Sign
-func (sig Signature) Sig() (sign *blscgo.Sign) {
-	sign = new(blscgo.Sign)
-	err := sign.SetStr(sig.String())
-	if err != nil {
-		log.Fatalln("Error in Signature conversion to blscgo.")
+// Sig -- convert Signature to blscgo Sign, deserialized on first use
+// The caller owns the returned copy and may modify it.
+func (sig Signature) Sig() (*blscgo.Sign, error) {
+	p := sig.point
+	if p == nil {
+		p = &signPoint{}
 	}
+	p.once.Do(func() {
+		p.sign = new(blscgo.Sign)
+		if p.sign.SetStr(sig.String()) != nil {
+			logger.Error("Signature conversion to blscgo failed", "sig", sig.String())
+			p.sign, p.err = nil, ErrDeserialize
+		}
+	})
+	if p.err != nil {
+		return nil, p.err
+	}
+	sign := *p.sign
+	return &sign, nil
+}
+
+// Constructors
+
+// signatureFromCgo -- wrap a blscgo point, the Signature takes ownership of sign
+func signatureFromCgo(sign *blscgo.Sign) (sig Signature) {
+	sig.value = []byte(sign.String())
+	sig.point = &signPoint{}
+	sig.point.once.Do(func() { sig.point.sign = sign })
 	return
 }
 
+// SignatureFromString -- a Signature from its string representation, deserialized on first use
+func SignatureFromString(s string) Signature {
+	return Signature{value: []byte(s), point: &signPoint{}}
+}
+
 // Sign -- sign a message with secret key
-func Sign(sec Seckey, msg []byte) (sig Signature) {
+func Sign(sec Seckey, msg []byte) (sig Signature, err error) {
 	sigGenCalls++
 	// convert Seckey to blscgo.SecretKey
-	sk := sec.SecretKey()
+	sk, err := sec.SecretKey()
+	if err != nil {
+		return
+	}
+	defer sk.Clear()
 	// sign
 	sign := sk.Sign(string(msg))
 	// convert back from blscgo
-	sig.value = []byte(sign.String())
+	sig = signatureFromCgo(sign)
 	return
 }
 
 // Verifying
 
 // VerifySig -- verify message and signature against public key
+// Values that cannot be deserialized do not verify.
 func VerifySig(pub Pubkey, msg []byte, sig Signature) bool {
 	sigVerifyCalls++
 	// convert to blscgo and verify
-	return sig.Sig().Verify(pub.PublicKey(), string(msg))
+	sign, err := sig.Sig()
+	if err != nil {
+		return false
+	}
+	pk, err := pub.PublicKey()
+	if err != nil {
+		return false
+	}
+	return sign.Verify(pk, string(msg))
 }
 
 // VerifyAggregateSig --
 func VerifyAggregateSig(pubs []Pubkey, msg []byte, asig Signature) bool {
-	return VerifySig(AggregatePubkeys(pubs), msg, asig)
+	apub, err := AggregatePubkeys(pubs)
+	if err != nil {
+		return false
+	}
+	return VerifySig(apub, msg, asig)
 }
 
 // BatchVerify --
 func BatchVerify(pubs []Pubkey, msg []byte, sigs []Signature) bool {
-	return VerifyAggregateSig(pubs, msg, AggregateSigs(sigs))
+	asig, err := AggregateSigs(sigs)
+	if err != nil {
+		return false
+	}
+	return VerifyAggregateSig(pubs, msg, asig)
+}
+
+// VerifyMultiSig -- verify an aggregate signature where msgs[i] was signed under pubs[i]
+// The messages have to be pairwise distinct, otherwise the check fails.
+// This checks e(asig, Q) * prod e(-H(msgs[i]), pubs[i]) == 1 with a single final exponentiation.
+func VerifyMultiSig(pubs []Pubkey, msgs [][]byte, asig Signature) bool {
+	sigVerifyCalls++
+	if len(pubs) != len(msgs) || len(pubs) == 0 {
+		return false
+	}
+	sign, err := asig.Sig()
+	if err != nil {
+		return false
+	}
+	gen, err := blscgo.GeneratorPublicKey()
+	if err != nil {
+		return false
+	}
+	g1 := make([]blscgo.Sign, len(pubs)+1)
+	g2 := make([]blscgo.PublicKey, len(pubs)+1)
+	g1[0], g2[0] = *sign, *gen
+	// distinct messages rule out rogue-key attacks on the aggregate
+	seen := make(map[string]bool)
+	for i, pub := range pubs {
+		if seen[string(msgs[i])] {
+			logger.Debug("duplicate message in multi-signature", "i", i)
+			return false
+		}
+		seen[string(msgs[i])] = true
+		pk, err := pub.PublicKey()
+		if err != nil {
+			return false
+		}
+		h := blscgo.HashToSign(string(msgs[i]))
+		h.Neg()
+		g1[i+1], g2[i+1] = *h, *pk
+	}
+	return blscgo.MultiPairingIsOne(g1, g2)
 }
 
 // Aggregation and Recovery
 
 // AggregateSigs -- aggregate multiple into one by summing up
-func AggregateSigs(sigs []Signature) (sig Signature) {
+func AggregateSigs(sigs []Signature) (sig Signature, err error) {
 	sigAggCalls++
 	sigAggLen += len(sigs)
+	if len(sigs) == 0 {
+		return sig, ErrEmpty
+	}
 	// convert to blscgo
-	sum := sigs[0].Sig()
+	sum, err := sigs[0].Sig()
+	if err != nil {
+		return
+	}
 	// sum it up
 	for _, s := range sigs[1:] {
-		fmt.Println("agg sigs")
-		sum.Add(s.Sig())
+		logger.Debug("agg sigs")
+		sign, err := s.Sig()
+		if err != nil {
+			return sig, err
+		}
+		sum.Add(sign)
 	}
 	// convert back from blscgo
-	sig.value = []byte(sum.String())
+	sig = signatureFromCgo(sum)
 	return
 }
 
 // RecoverSignature -- Recover master from shares through Lagrange interpolation
-func RecoverSignature(sigs []Signature, ids []ID) (sig Signature) {
+func RecoverSignature(sigs []Signature, ids []ID) (sig Signature, err error) {
 	sigRecoverCalls++
 	sigRecoverLen += len(sigs)
+	if len(sigs) == 0 {
+		return sig, ErrTooFewShares
+	}
 
 	// convert sigs to blscgo
 	signVec := make([]blscgo.Sign, len(sigs))
 	for i, s := range sigs {
-		signVec[i] = *(s.Sig())
+		sign, err := s.Sig()
+		if err != nil {
+			return sig, err
+		}
+		signVec[i] = *sign
 	}
 	// convert ids to blscgo
 	idVec := make([]blscgo.ID, len(ids))
 	for i, id := range ids {
-		idVec[i] = id.CgoID()
+		idVec[i], err = id.CgoID()
+		if err != nil {
+			return
+		}
 	}
 
-	var sign blscgo.Sign
+	sign := new(blscgo.Sign)
 	sign.Recover(signVec, idVec)
 
-	sig.value = []byte(sign.String())
+	sig = signatureFromCgo(sign)
 	return
 }
 
-// RecoverSignatureByMap --
-func RecoverSignatureByMap(m SignatureMap, k int) (sec Signature) {
-	ids := make([]ID, k)
+// RecoverSignatureByMap -- recover from the k shares with the smallest IDs, ids as in RecoverSeckeyByMap
+func RecoverSignatureByMap(m SignatureMap, ids IDMap, k int) (sig Signature, err error) {
+	addrs := make([]common.Address, 0, len(m))
+	for a := range m {
+		addrs = append(addrs, a)
+	}
+	addrs, sel, err := ids.selectIDs(addrs, k)
+	if err != nil {
+		return
+	}
 	sigs := make([]Signature, k)
-	i := 0
-	for a, s := range m {
-		ids[i] = IDFromAddress(a)
-		sigs[i] = s
-		i++
-		if i >= k {
-			break
-		}
+	for i, a := range addrs {
+		sigs[i] = m[a]
 	}
-	return RecoverSignature(sigs, ids)
+	return RecoverSignature(sigs, sel)
 }
@@ -0,0 +1,157 @@
+package bls
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
+
+// VerificationVector -- public commitments to the coefficients of a sharing polynomial
+// Entry i is the pubkey of coefficient i, entry 0 is the pubkey of the shared secret.
+// The length of the vector is the threshold.
+type VerificationVector []Pubkey
+
+// vvecSep -- separator between the pubkeys in the serialization, does not occur in a Pubkey string
+const vvecSep = ";"
+
+// Constructors
+
+// VerificationVectorFromSeckeys -- commit to the coefficients msec of a polynomial
+func VerificationVectorFromSeckeys(msec []Seckey) (vvec VerificationVector, err error) {
+	vvec = make(VerificationVector, len(msec))
+	for i, sec := range msec {
+		if vvec[i], err = PubkeyFromSeckey(sec); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// VerificationVectorFromString -- inverse of String
+func VerificationVectorFromString(s string) (VerificationVector, error) {
+	if s == "" {
+		return nil, ErrEmpty
+	}
+	parts := strings.Split(s, vvecSep)
+	vvec := make(VerificationVector, len(parts))
+	for i, p := range parts {
+		vvec[i] = PubkeyFromString(p)
+		if _, err := vvec[i].PublicKey(); err != nil {
+			return nil, err
+		}
+	}
+	return vvec, nil
+}
+
+// CombineVerificationVectors -- the vector of the sum of the dealers' polynomials
+// All vectors need to have the same length.
+func CombineVerificationVectors(vvecs []VerificationVector) (VerificationVector, error) {
+	if len(vvecs) == 0 {
+		return nil, ErrEmpty
+	}
+	k := len(vvecs[0])
+	combined := make(VerificationVector, k)
+	pubs := make([]Pubkey, len(vvecs))
+	for i := 0; i < k; i++ {
+		for j, v := range vvecs {
+			if len(v) != k {
+				logger.Error("verification vectors differ in length", "want", k, "got", len(v))
+				return nil, ErrVvecLength
+			}
+			pubs[j] = v[i]
+		}
+		var err error
+		if combined[i], err = AggregatePubkeys(pubs); err != nil {
+			return nil, err
+		}
+	}
+	return combined, nil
+}
+
+// RecoverVerificationVector -- Lagrange-weighted combination of the vectors of dealers with the given IDs
+// If dealer i shared the value of a polynomial f at ids[i], the result is the vector of the sharing of f(0).
+func RecoverVerificationVector(vvecs []VerificationVector, ids []ID) (VerificationVector, error) {
+	if len(vvecs) == 0 || len(vvecs) != len(ids) {
+		return nil, ErrTooFewShares
+	}
+	if err := ValidateIDs(ids); err != nil {
+		return nil, err
+	}
+	k := len(vvecs[0])
+	recovered := make(VerificationVector, k)
+	pubs := make([]Pubkey, len(vvecs))
+	for i := 0; i < k; i++ {
+		for j, v := range vvecs {
+			if len(v) != k {
+				logger.Error("verification vectors differ in length", "want", k, "got", len(v))
+				return nil, ErrVvecLength
+			}
+			pubs[j] = v[i]
+		}
+		var err error
+		if recovered[i], err = RecoverPubkey(pubs, ids); err != nil {
+			return nil, err
+		}
+	}
+	return recovered, nil
+}
+
+// Getters
+
+// Threshold -- the number of shares needed for recovery
+func (vvec VerificationVector) Threshold() int {
+	return len(vvec)
+}
+
+// Pubkey -- the pubkey of the shared secret
+func (vvec VerificationVector) Pubkey() Pubkey {
+	if len(vvec) == 0 {
+		return Pubkey{}
+	}
+	return vvec[0]
+}
+
+// IsZeroSharing -- whether the vector commits to a sharing of zero, i.e. its constant term is the identity
+// Adding such a sharing to the shares of a group changes the shares but not the group pubkey.
+func (vvec VerificationVector) IsZeroSharing() bool {
+	if len(vvec) == 0 {
+		return false
+	}
+	zero, err := PubkeyFromSeckey(SeckeyFromInt(0))
+	if err != nil {
+		return false
+	}
+	return vvec[0].String() == zero.String()
+}
+
+// PubkeyAt -- the pubkey of the share of id
+func (vvec VerificationVector) PubkeyAt(id ID) (Pubkey, error) {
+	return SharePubkey(vvec, id)
+}
+
+// PubkeyAtAddr -- wrapper around PubkeyAt for member addresses
+func (vvec VerificationVector) PubkeyAtAddr(addr common.Address) (Pubkey, error) {
+	return vvec.PubkeyAt(IDFromAddress(addr))
+}
+
+// VerifySigShare -- check the signature share of id on msg
+func (vvec VerificationVector) VerifySigShare(id ID, msg []byte, sig Signature) bool {
+	pub, err := vvec.PubkeyAt(id)
+	if err != nil {
+		return false
+	}
+	return VerifySig(pub, msg, sig)
+}
+
+// Strings -- the serialized pubkeys
+func (vvec VerificationVector) Strings() []string {
+	s := make([]string, len(vvec))
+	for i, pub := range vvec {
+		s[i] = pub.String()
+	}
+	return s
+}
+
+// String -- serialization, see VerificationVectorFromString
+func (vvec VerificationVector) String() string {
+	return strings.Join(vvec.Strings(), vvecSep)
+}
@@ -20,9 +20,21 @@ const CurveFp382_1 = 1
 // CurveFp382_2 -- 382 bit curve 2
 const CurveFp382_2 = 2
 
+// Curves -- the supported curves by name
+var Curves = map[string]int{"bn254": CurveFp254BNb, "bn382_1": CurveFp382_1, "bn382_2": CurveFp382_2}
+
+// the curve of the last Init, -1 before the first one
+var current = -1
+
 // Init --
 func Init(curve int) {
 	C.blsInit(C.int(curve), C.BLS_MAX_OP_UNIT_SIZE)
+	current = curve
+}
+
+// Curve -- the curve passed to the last Init, -1 if Init was not called
+func Curve() int {
+	return current
 }
 
 // GetMaxOpUnitSize --
@@ -126,6 +138,13 @@ func (sec *SecretKey) Init() {
 	C.blsSecretKeyInit(sec.getPointer())
 }
 
+// Clear -- overwrite the secret key with zeros
+func (sec *SecretKey) Clear() {
+	for i := range sec.v {
+		sec.v[i] = 0
+	}
+}
+
 // Add --
 func (sec *SecretKey) Add(rhs *SecretKey) {
 	C.blsSecretKeyAdd(sec.getPointer(), rhs.getPointer())
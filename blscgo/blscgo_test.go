@@ -177,6 +177,43 @@ func TestPop(t *testing.T) {
 	}
 }
 
+func TestMultiPairing(t *testing.T) {
+	t.Log("testMultiPairing")
+	Init(curve)
+	m := []string{"multi 1", "multi 2", "multi 3"}
+	g1 := make([]Sign, len(m)+1)
+	g2 := make([]PublicKey, len(m)+1)
+	gen, err := GeneratorPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var asig *Sign
+	for i := range m {
+		var sec SecretKey
+		sec.Init()
+		s := sec.Sign(m[i])
+		if i == 0 {
+			asig = s
+		} else {
+			asig.Add(s)
+		}
+		h := HashToSign(m[i])
+		if !Pairing(s, gen).IsEqual(Pairing(h, sec.GetPublicKey())) {
+			t.Error("Pairing does not match signature")
+		}
+		h.Neg()
+		g1[i+1], g2[i+1] = *h, *sec.GetPublicKey()
+	}
+	g1[0], g2[0] = *asig, *gen
+	if !MultiPairingIsOne(g1, g2) {
+		t.Error("Multi-pairing of aggregate signature is not one")
+	}
+	g1[0] = g1[1]
+	if MultiPairingIsOne(g1, g2) {
+		t.Error("Multi-pairing of wrong signature is one")
+	}
+}
+
 func BenchmarkPubkeyFromSeckey(b *testing.B) {
 	b.StopTimer()
 	Init(curve)
@@ -0,0 +1,135 @@
+package blscgo
+
+/*
+#define MCLBN_FP_UNIT_SIZE BLS_MAX_OP_UNIT_SIZE
+#include "bls_if.h"
+#include "mcl/bn.h"
+*/
+import "C"
+import "unsafe"
+
+// The pairing primitives below call the C API of mcl directly.
+// They rely on blsSign being a point in G1 and blsPublicKey being a point in G2
+// (memory layout of mclBnG1 and mclBnG2), which is how bls_if represents them.
+
+// GT -- element of the target group of the pairing
+type GT struct {
+	v [C.BLS_MAX_OP_UNIT_SIZE * 12]C.uint64_t
+}
+
+// getPointer --
+func (e *GT) getPointer() (p *C.mclBnGT) {
+	// #nosec
+	return (*C.mclBnGT)(unsafe.Pointer(&e.v[0]))
+}
+
+// g1Pointer --
+func (sign *Sign) g1Pointer() (p *C.mclBnG1) {
+	// #nosec
+	return (*C.mclBnG1)(unsafe.Pointer(&sign.v[0]))
+}
+
+// g2Pointer --
+func (pub *PublicKey) g2Pointer() (p *C.mclBnG2) {
+	// #nosec
+	return (*C.mclBnG2)(unsafe.Pointer(&pub.v[0]))
+}
+
+// Mul --
+func (e *GT) Mul(rhs *GT) {
+	C.mclBnGT_mul(e.getPointer(), e.getPointer(), rhs.getPointer())
+}
+
+// IsEqual --
+func (e *GT) IsEqual(rhs *GT) bool {
+	return C.mclBnGT_isEqual(e.getPointer(), rhs.getPointer()) == 1
+}
+
+// IsOne --
+func (e *GT) IsOne() bool {
+	return C.mclBnGT_isOne(e.getPointer()) == 1
+}
+
+// MillerLoop -- the pairing of sign and pub without the final exponentiation
+func MillerLoop(sign *Sign, pub *PublicKey) (e *GT) {
+	e = new(GT)
+	C.mclBn_millerLoop(e.getPointer(), sign.g1Pointer(), pub.g2Pointer())
+	return e
+}
+
+// FinalExp --
+func (e *GT) FinalExp() {
+	C.mclBn_finalExp(e.getPointer(), e.getPointer())
+}
+
+// Pairing -- e(sign, pub)
+func Pairing(sign *Sign, pub *PublicKey) (e *GT) {
+	e = new(GT)
+	C.mclBn_pairing(e.getPointer(), sign.g1Pointer(), pub.g2Pointer())
+	return e
+}
+
+// MultiPairingIsOne -- check that the product of e(g1[i], g2[i]) over all i is one
+// The Miller loops are multiplied up and share a single final exponentiation.
+func MultiPairingIsOne(g1 []Sign, g2 []PublicKey) bool {
+	if len(g1) != len(g2) || len(g1) == 0 {
+		return false
+	}
+	f := MillerLoop(&g1[0], &g2[0])
+	for i := 1; i < len(g1); i++ {
+		f.Mul(MillerLoop(&g1[i], &g2[i]))
+	}
+	f.FinalExp()
+	return f.IsOne()
+}
+
+// Mul -- multiply the point by a scalar
+func (sign *Sign) Mul(k *SecretKey) {
+	// #nosec
+	C.mclBnG1_mul(sign.g1Pointer(), sign.g1Pointer(), (*C.mclBnFr)(unsafe.Pointer(&k.v[0])))
+}
+
+// Mul -- multiply the point by a scalar
+func (pub *PublicKey) Mul(k *SecretKey) {
+	// #nosec
+	C.mclBnG2_mul(pub.g2Pointer(), pub.g2Pointer(), (*C.mclBnFr)(unsafe.Pointer(&k.v[0])))
+}
+
+// Neg -- negate the point
+func (sign *Sign) Neg() {
+	C.mclBnG1_neg(sign.g1Pointer(), sign.g1Pointer())
+}
+
+// HashToSign -- the point in G1 that the message is mapped to before signing
+func HashToSign(m string) (sign *Sign) {
+	sign = new(Sign)
+	buf := []byte(m)
+	var p unsafe.Pointer
+	if len(buf) > 0 {
+		p = unsafe.Pointer(&buf[0])
+	}
+	// #nosec
+	C.mclBnG1_hashAndMapTo(sign.g1Pointer(), p, C.size_t(len(buf)))
+	return sign
+}
+
+// GeneratorPublicKey -- the generator of G2 that public keys are derived from
+func GeneratorPublicKey() (*PublicKey, error) {
+	var sec SecretKey
+	if err := sec.SetStr("1"); err != nil {
+		return nil, err
+	}
+	return sec.GetPublicKey(), nil
+}
+
+// String -- hex encoding of the element, e.g. to derive a key from it
+func (e *GT) String() string {
+	// an element of GT has 12 coordinates
+	buf := make([]byte, 4096)
+	// #nosec
+	n := C.mclBnGT_getStr((*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)), e.getPointer(), 16)
+	if n == 0 {
+		panic("implementation err. size of buf is small")
+	}
+	return string(buf[:n])
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Logger -- structured logger, keyvals are alternating keys and values
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// NopLogger -- a Logger that discards all entries
+type NopLogger struct{}
+
+// Debug --
+func (NopLogger) Debug(msg string, keyvals ...interface{}) {}
+
+// Info --
+func (NopLogger) Info(msg string, keyvals ...interface{}) {}
+
+// Error --
+func (NopLogger) Error(msg string, keyvals ...interface{}) {}
+
+// TextLogger -- a Logger that writes one line of key=value pairs per entry
+type TextLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	debug bool
+}
+
+// NewTextLogger -- create a TextLogger writing to w, debug entries are dropped unless debug is set
+func NewTextLogger(w io.Writer, debug bool) *TextLogger {
+	return &TextLogger{w: w, debug: debug}
+}
+
+// Debug --
+func (l *TextLogger) Debug(msg string, keyvals ...interface{}) {
+	if l.debug {
+		l.write("debug", msg, keyvals)
+	}
+}
+
+// Info --
+func (l *TextLogger) Info(msg string, keyvals ...interface{}) {
+	l.write("info", msg, keyvals)
+}
+
+// Error --
+func (l *TextLogger) Error(msg string, keyvals ...interface{}) {
+	l.write("error", msg, keyvals)
+}
+
+// write --
+func (l *TextLogger) write(level string, msg string, keyvals []interface{}) {
+	line := fmt.Sprintf("t=%s lvl=%s msg=%q", time.Now().Format(time.RFC3339Nano), level, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			line += fmt.Sprintf(" %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			line += fmt.Sprintf(" %v=?", keyvals[i])
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, line)
+}
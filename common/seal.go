@@ -0,0 +1,43 @@
+package common
+
+import (
+	"encoding/binary"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Symmetric encryption for the hybrid schemes (timelock, elgamal): the key is a hash derived from a
+// group element, the body is the payload xor a Keccak keystream, the tag a Keccak MAC over the body.
+
+// Seal -- encrypt and authenticate the payload under key k
+func Seal(k common.Hash, payload []byte) ([]byte, common.Hash) {
+	body := keystream(k, payload)
+	return body, mac(k, body)
+}
+
+// Open -- check the tag and decrypt the body, false if the tag does not match
+func Open(k common.Hash, body []byte, tag common.Hash) ([]byte, bool) {
+	if mac(k, body) != tag {
+		return nil, false
+	}
+	return keystream(k, body), true
+}
+
+// keystream -- xor data with the keystream of key k, hash blocks of k and a counter
+func keystream(k common.Hash, data []byte) []byte {
+	out := make([]byte, len(data))
+	var ctr [8]byte
+	for i := 0; i < len(data); i += common.HashLength {
+		binary.BigEndian.PutUint64(ctr[:], uint64(i/common.HashLength))
+		block := crypto.Keccak256(k[:], []byte("enc"), ctr[:])
+		for j := i; j < len(data) && j < i+common.HashLength; j++ {
+			out[j] = data[j] ^ block[j-i]
+		}
+	}
+	return out
+}
+
+// mac -- the authentication tag of the body under key k
+func mac(k common.Hash, body []byte) common.Hash {
+	return crypto.Keccak256Hash(k[:], []byte("mac"), body)
+}
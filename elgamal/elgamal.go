@@ -0,0 +1,188 @@
+// Package elgamal implements threshold ElGamal decryption with the keys of beacon groups
+//
+// A client encrypts to the pubkey P = x*G2 of a registered group: with a random r it publishes U = r*G2
+// and derives the key of the payload from r*P. The members hold Shamir shares x_i of x from the group
+// setup. Each member publishes the decryption share D_i = x_i*U, any k shares recover x*U = r*P by
+// Lagrange interpolation in the exponent, just like signature shares recover the group signature.
+//
+// A decryption share comes with a proof of correctness: the member's signature share S_i on the
+// ciphertext. S_i verifies against the member pubkey x_i*G2 from the group's verification vector, and
+// e(S_i, U) = e(H, D_i) shows that D_i carries the same exponent x_i.
+//
+// The sender signs the ciphertext with r, the signature verifies against U. Members only produce shares for
+// ciphertexts with a valid signature: like the proof of knowledge of r in TDH2, it binds U to the body, so
+// nobody but the sender can submit a modified ciphertext with the same U to obtain x*U for it.
+package elgamal
+
+import (
+	"crypto/rand"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	dfn "dfinity/beacon/common"
+	"dfinity/beacon/state"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"io"
+)
+
+// Errors
+
+// ErrNoPubkey -- the group has no pubkey to encrypt to
+var ErrNoPubkey = errors.New("elgamal: group has no pubkey")
+
+// ErrTooFewShares -- less valid decryption shares than the threshold
+var ErrTooFewShares = errors.New("elgamal: not enough valid decryption shares")
+
+// ErrCiphertext -- the ciphertext is not signed with the sender's r
+var ErrCiphertext = errors.New("elgamal: invalid ciphertext signature")
+
+// ErrAuth -- the ciphertext was modified, or the shares are for another ciphertext
+var ErrAuth = errors.New("elgamal: authentication failed")
+
+// types
+
+// Ciphertext -- a payload encrypted to the pubkey of a group
+type Ciphertext struct {
+	// the pubkey of the group, it stays the same when the group hands its key over to new members
+	Pubkey bls.Pubkey
+	// r*G2 for the random r of the sender
+	U    bls.Pubkey
+	Body []byte
+	Tag  common.Hash
+	// signature with r on the label
+	Sig bls.Signature
+}
+
+// Share -- the decryption share of a member with its proof of correctness
+type Share struct {
+	Member common.Address
+	// x_i*U
+	D bls.Pubkey
+	// signature share on the ciphertext's label
+	Proof bls.Signature
+}
+
+// Encrypt -- encrypt the payload to the pubkey of group g
+func Encrypt(g state.Group, payload []byte) (Ciphertext, error) {
+	return EncryptFrom(rand.Reader, g, payload)
+}
+
+// EncryptFrom -- Encrypt with randomness from the given reader
+func EncryptFrom(rnd io.Reader, g state.Group, payload []byte) (c Ciphertext, err error) {
+	pub := g.Pubkey()
+	if pub.String() == "" {
+		return c, ErrNoPubkey
+	}
+	var b [bls.SeckeyLength]byte
+	if _, err = io.ReadFull(rnd, b[:]); err != nil {
+		return
+	}
+	r := bls.SeckeyFromBytes(b[:])
+	defer r.Destroy()
+	c.Pubkey = pub
+	if c.U, err = bls.PubkeyFromSeckey(r); err != nil {
+		return
+	}
+	s, err := bls.MulPubkey(pub, r)
+	if err != nil {
+		return
+	}
+	c.Body, c.Tag = dfn.Seal(c.key(s), payload)
+	c.Sig, err = bls.Sign(r, c.Label())
+	return
+}
+
+// Label -- the message that the sender and the proofs of decryption shares sign, it commits to the whole ciphertext
+func (c Ciphertext) Label() []byte {
+	return crypto.Keccak256([]byte("elgamal"), []byte(c.Pubkey.String()), []byte(c.U.String()), crypto.Keccak256(c.Body), c.Tag[:])
+}
+
+// Valid -- check the signature of the sender
+func (c Ciphertext) Valid() bool {
+	return bls.VerifySig(c.U, c.Label(), c.Sig)
+}
+
+// key -- the symmetric key derived from the shared value r*P
+func (c Ciphertext) key(s bls.Pubkey) common.Hash {
+	return crypto.Keccak256Hash([]byte("elgamal"), []byte(s.String()), []byte(c.U.String()), []byte(c.Pubkey.String()))
+}
+
+// NewShare -- the decryption share of the member with secret share sec, for a valid ciphertext only
+func NewShare(member common.Address, sec bls.Seckey, c Ciphertext) (Share, error) {
+	if !c.Valid() {
+		return Share{}, ErrCiphertext
+	}
+	d, err := bls.MulPubkey(c.U, sec)
+	if err != nil {
+		return Share{}, err
+	}
+	proof, err := bls.Sign(sec, c.Label())
+	if err != nil {
+		return Share{}, err
+	}
+	return Share{member, d, proof}, nil
+}
+
+// VerifyShare -- check the ciphertext and the decryption share against the verification vector of group g
+func VerifyShare(g state.Group, c Ciphertext, s Share) bool {
+	return c.Valid() && verifyShare(g, c.U, c.Label(), s)
+}
+
+// verifyShare -- check the proof of the decryption share for the given U and label
+func verifyShare(g state.Group, U bls.Pubkey, label []byte, s Share) bool {
+	if !g.VerifySigShare(s.Member, label, s.Proof) {
+		return false
+	}
+	sign, err := s.Proof.Sig()
+	if err != nil {
+		return false
+	}
+	u, err := U.PublicKey()
+	if err != nil {
+		return false
+	}
+	d, err := s.D.PublicKey()
+	if err != nil {
+		return false
+	}
+	return blscgo.Pairing(sign, u).IsEqual(blscgo.Pairing(blscgo.HashToSign(string(label)), d))
+}
+
+// Combine -- decrypt with the first k valid shares of distinct members, k the threshold of group g
+// Returns the payload and the members whose shares were used.
+func Combine(g state.Group, c Ciphertext, shares []Share) ([]byte, []common.Address, error) {
+	if !c.Valid() {
+		return nil, nil, ErrCiphertext
+	}
+	label := c.Label()
+	var ds []bls.Pubkey
+	var used []common.Address
+	seen := make(map[common.Address]bool)
+	for _, s := range shares {
+		if len(ds) == g.Threshold() {
+			break
+		}
+		if !seen[s.Member] && verifyShare(g, c.U, label, s) {
+			ds = append(ds, s.D)
+			used = append(used, s.Member)
+			seen[s.Member] = true
+		}
+	}
+	if len(ds) < g.Threshold() {
+		return nil, nil, ErrTooFewShares
+	}
+	ids, err := g.MemberIDs(used)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := bls.RecoverPubkey(ds, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, ok := dfn.Open(c.key(s), c.Body, c.Tag)
+	if !ok {
+		return nil, nil, ErrAuth
+	}
+	return payload, used, nil
+}
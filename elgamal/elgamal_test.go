@@ -0,0 +1,115 @@
+package elgamal
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"dfinity/beacon/state"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestThresholdDecryption(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	n, k := 5, 3
+	addrs := make([]common.Address, n)
+	for i := range addrs {
+		addrs[i] = common.BytesToAddress([]byte{byte(i + 1)})
+	}
+	g, err := state.NewGroup(addrs, uint16(k), state.IDByIndex, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msec := make([]bls.Seckey, k)
+	for i := range msec {
+		msec[i] = bls.SeckeyFromInt(int64(100 + i))
+	}
+	vvec, err := bls.VerificationVectorFromSeckeys(msec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetVerificationVector(vvec); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("decrypted by any three of five members")
+	c, err := Encrypt(g, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares := make([]Share, n)
+	for i, a := range addrs {
+		id, err := g.MemberID(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sec, err := bls.ShareSeckey(msec, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shares[i], err = NewShare(a, sec, c); err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyShare(g, c, shares[i]) {
+			t.Errorf("Share %d does not verify", i)
+		}
+	}
+
+	// any k shares decrypt
+	out, used, err := Combine(g, c, shares[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, payload) || len(used) != k {
+		t.Errorf("Decrypted %q with %d shares", out, len(used))
+	}
+
+	// a share with a wrong D or by the wrong member is rejected and skipped
+	bad := shares[0]
+	bad.D = shares[1].D
+	if VerifyShare(g, c, bad) {
+		t.Error("Share with wrong D verifies")
+	}
+	moved := shares[1]
+	moved.Member = addrs[0]
+	if VerifyShare(g, c, moved) {
+		t.Error("Share of another member verifies")
+	}
+	if out, _, err := Combine(g, c, []Share{bad, moved, shares[2], shares[3], shares[3], shares[4]}); err != nil || !bytes.Equal(out, payload) {
+		t.Error("Invalid and duplicate shares not skipped:", err)
+	}
+	if _, _, err := Combine(g, c, []Share{bad, shares[2], shares[3], shares[3]}); err != ErrTooFewShares {
+		t.Error("Decrypted with less than k valid shares:", err)
+	}
+
+	// a ciphertext modified by anybody but the sender is rejected before shares are produced
+	id, err := g.MemberID(addrs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec, err := bls.ShareSeckey(msec, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := c
+	mod.Body = append([]byte{}, c.Body...)
+	mod.Body[0] ^= 1
+	if _, err := NewShare(addrs[0], sec, mod); err != ErrCiphertext {
+		t.Error("Share for modified body:", err)
+	}
+	if VerifyShare(g, mod, shares[0]) {
+		t.Error("Share verifies for modified body")
+	}
+	if _, _, err := Combine(g, mod, shares); err != ErrCiphertext {
+		t.Error("Modified body not detected:", err)
+	}
+	c2, err := Encrypt(g, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod = c2
+	mod.U = c.U
+	if mod.Valid() {
+		t.Error("Ciphertext with another sender's U is valid")
+	}
+}
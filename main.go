@@ -1,18 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"dfinity/beacon/bls"
 	"dfinity/beacon/blscgo"
+	dfn "dfinity/beacon/common"
+	"dfinity/beacon/elgamal"
 	"dfinity/beacon/sim"
+	"dfinity/beacon/state"
+	"dfinity/beacon/timelock"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
 )
 
 func main() {
-	var l, n, k, N, m uint
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		bench(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "timelock" {
+		timelockCmd(os.Args[2:])
+		return
+	}
+
+	var l, n, k, N, m, refresh, at, proposers, delay, decrypt, form uint
 	var seedstr string
-	var bist, vvec, timing bool
-	var curve string
+	var bist, vvec, timing, debug, notarize bool
+	var curve, format, recordfile, replayfile, idmode, exportfile, snapshotfile, rootstr string
 	flag.UintVar(&l, "l", 20, "Length of chain (number of blocks to create)")
 	flag.UintVar(&n, "n", 3, "Group size")
 	flag.UintVar(&k, "k", 2, "Threshold")
@@ -22,48 +42,396 @@ func main() {
 	flag.BoolVar(&bist, "bist", false, "Enable Built-in self test")
 	flag.BoolVar(&vvec, "vvec", false, "Enable validation against verification vector")
 	flag.BoolVar(&timing, "timing", false, "Enable output of timing information")
+	flag.BoolVar(&debug, "debug", false, "Enable debug logging")
 	flag.StringVar(&curve, "curve", "bn382_1", "Pairing type")
+	flag.UintVar(&refresh, "refresh", 0, "Refresh the group shares every R blocks (0 disables refreshes)")
+	flag.UintVar(&form, "form", 0, "Form a new group every F blocks, certified by an existing group (0 disables group formation)")
+	flag.UintVar(&proposers, "proposers", 0, "Number of highest-ranked nodes that propose a candidate for each block (0 disables proposals)")
+	flag.BoolVar(&notarize, "notarize", false, "Enable notarization of candidate blocks and fork choice")
+	flag.UintVar(&delay, "delay", 0, "Percentage of rounds in which the notarization of the best candidate is delayed (with -notarize and -proposers of at least 2)")
+	flag.UintVar(&decrypt, "decrypt", 0, "Number of threshold decryption requests that a client submits to the groups in every round")
+	flag.StringVar(&idmode, "ids", "address", "IDs of group members for secret sharing (address or index)")
+	flag.StringVar(&format, "format", sim.FormatText, "Output format (text, json, ndjson or none)")
+	flag.StringVar(&recordfile, "record", "", "Write the transcript of the run to this file")
+	flag.StringVar(&replayfile, "replay", "", "Replay the run recorded in this file and compare transcripts")
+	flag.StringVar(&exportfile, "export", "", "Write a snapshot of the state after the run to this file")
+	flag.UintVar(&at, "at", 0, "Height of the exported snapshot (0 for the last block)")
+	flag.StringVar(&snapshotfile, "snapshot", "", "Start from the snapshot in this file instead of the genesis block (needs -root and the seed of the original run)")
+	flag.StringVar(&rootstr, "root", "", "Trusted state root of the snapshot")
 	flag.Parse()
 
+	if format != sim.FormatText && format != sim.FormatJSON && format != sim.FormatNDJSON && format != sim.FormatNone {
+		fmt.Printf("not supported format %s\n", format)
+		return
+	}
+	sim.Format = format
+	text := !sim.Structured()
+
+	// log to stderr so that it never mixes with the simulation output
+	logger := dfn.NewTextLogger(os.Stderr, debug)
+	bls.SetLogger(logger)
+	state.SetLogger(logger)
+	sim.SetLogger(logger)
+
 	// init Cgo
-	if curve == "bn254" {
-		fmt.Println("bn254")
-		blscgo.Init(blscgo.CurveFp254BNb)
-	} else if curve == "bn382_1" {
-		fmt.Println("bn382_1")
-		blscgo.Init(blscgo.CurveFp382_1)
-	} else if curve == "bn382_2" {
-		fmt.Println("bn382_2")
-		blscgo.Init(blscgo.CurveFp382_2)
-	} else {
+	c, ok := blscgo.Curves[curve]
+	if !ok {
 		fmt.Printf("not supported curve %s\n", curve)
 		return
 	}
+	blscgo.Init(c)
+	if text {
+		fmt.Println(curve)
+	}
 
-	seed := bls.RandFromBytes([]byte(seedstr))
+	mode, ok := state.IDModes[idmode]
+	if !ok {
+		fmt.Printf("not supported id mode %s\n", idmode)
+		return
+	}
 	sim.DoubleCheck = bist
 	sim.Vvec = vvec
 	sim.Timing = timing
-	// seed, groupSize, threshold, nProcesses, nGroups
-	mysim := sim.NewBlockchainSimulator(seed, uint16(n), uint16(k), N, uint16(m))
-	fmt.Println("--- Genesis block ")
-	fmt.Printf("%d: %s", mysim.Length(), mysim.Tip().String(true))
-	fmt.Printf("--- Blockchain states: (l)%d\n", l)
+
+	if replayfile != "" {
+		f, err := os.Open(replayfile)
+		if err != nil {
+			logger.Error("cannot open transcript", "file", replayfile, "err", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		if err := sim.Replay(f); err != nil {
+			logger.Error("replay failed", "file", replayfile, "err", err)
+			os.Exit(1)
+		}
+		fmt.Printf("--- Replay of %s matches\n", replayfile)
+		return
+	}
+
+	seed := bls.RandFromBytes([]byte(seedstr))
+	params := sim.TranscriptParams{Seed: seed, GroupSize: uint16(n), Threshold: uint16(k), Processes: N, Groups: uint16(m), Length: l, Curve: curve, IDMode: mode, Refresh: refresh, Proposers: proposers, Notarize: notarize, Delay: delay, Form: form}
+	var recorder *sim.Transcript
+	if recordfile != "" {
+		recorder = sim.NewTranscript(params)
+	}
+	cfg := params.Config(recorder)
+	sim.Emit(sim.RunRecord{Type: "run", Curve: curve, Seed: hex.EncodeToString(seed.Bytes()), GroupSize: uint16(n), Threshold: uint16(k)})
+	var mysim sim.BlockchainSimulator
+	var err error
+	if snapshotfile != "" {
+		mysim, err = startFromSnapshot(seed, snapshotfile, rootstr, cfg)
+	} else {
+		// seed, groupSize, threshold, nProcesses, nGroups
+		mysim, err = sim.NewBlockchainSimulator(seed, uint16(n), uint16(k), N, uint16(m), cfg)
+	}
+	if err != nil {
+		logger.Error("simulator setup failed", "err", err)
+		os.Exit(1)
+	}
+	if text {
+		if snapshotfile != "" {
+			fmt.Println("--- Snapshot block ")
+		} else {
+			fmt.Println("--- Genesis block ")
+		}
+		fmt.Printf("%d: %s", mysim.Length(), mysim.Tip().String(true))
+		fmt.Printf("--- Blockchain states: (l)%d\n", l)
+	}
+	var requests []int
+	var payloads [][]byte
 	for i := uint(0); i < l; i++ {
-		mysim.Advance(1, false)
-		fmt.Printf("%3d: %s\n", mysim.Length(), mysim.Tip().String(false))
+		// the client encrypts to the groups in turn
+		groups := mysim.Tip().GroupAddressList()
+		for j := uint(0); j < decrypt; j++ {
+			g, _ := mysim.Tip().Group(groups[(int(i*decrypt+j))%len(groups)])
+			payload := []byte(fmt.Sprintf("request %d", len(requests)))
+			c, err := elgamal.Encrypt(g, payload)
+			if err != nil {
+				logger.Error("encryption failed", "grp", g.Address().Hex(), "err", err)
+				os.Exit(1)
+			}
+			requests = append(requests, mysim.RequestDecryption(c))
+			payloads = append(payloads, payload)
+		}
+		if err := mysim.Advance(1, false); err != nil {
+			logger.Error("simulation failed", "height", mysim.Length()+1, "err", err)
+			os.Exit(1)
+		}
+		if text {
+			fmt.Printf("%3d: %s\n", mysim.Length(), mysim.Tip().String(false))
+		}
+	}
+	sim.Flush()
+
+	if decrypt > 0 && text {
+		ok := 0
+		for i, id := range requests {
+			if r, done := mysim.Decryption(id); done && r.Err == nil && bytes.Equal(r.Payload, payloads[i]) {
+				ok++
+			}
+		}
+		fmt.Printf("--- Decryption requests: (submitted)%d (decrypted)%d\n", len(requests), ok)
+	}
+
+	if f := mysim.Forks(); f != nil && text {
+		head, final := f.Head(), f.Final()
+		forked := 0
+		for h := mysim.Base(); h <= head.Height; h++ {
+			if len(f.AtHeight(h)) > 1 {
+				forked++
+			}
+		}
+		fmt.Printf("--- Notarized chain: (head)%d (rank)%d (final)%d (forked heights)%d\n", head.Height, head.Rank, final.Height, forked)
 	}
 
-	if timing {
+	if form > 0 && text {
+		// a light client learns the key of the current signing group from the first block and the certificate chain
+		a := mysim.Tip().SelectedGroupAddress()
+		chain, ok := mysim.History().CertificateChain(a)
+		pub, verified := state.VerifyCertificateChain(mysim.Block(mysim.Base()).GroupPubkeys(), a, chain)
+		verified = ok && verified && pub.String() == mysim.Tip().GroupPubkey(a).String()
+		fmt.Printf("--- Certificate chain of the selected group: (grp)%.2x (m)%d (length)%d (verified)%v\n", a[:2], len(mysim.Tip().GroupAddressList()), len(chain), verified)
+	}
+
+	if snapshotfile != "" {
+		// verify all blocks from the checkpoint on
+		if err := mysim.VerifyChain(); err != nil {
+			logger.Error("chain verification failed", "err", err)
+			os.Exit(1)
+		}
+		if text {
+			fmt.Printf("--- Chain verified from height %d\n", mysim.Base())
+		}
+	}
+
+	if exportfile != "" {
+		h := int(at)
+		if h == 0 {
+			h = mysim.Length()
+		}
+		if h < mysim.Base() || h > mysim.Length() {
+			logger.Error("no block at snapshot height", "height", h, "base", mysim.Base(), "tip", mysim.Length())
+			os.Exit(1)
+		}
+		f, err := os.Create(exportfile)
+		if err == nil {
+			err = mysim.Block(h).WriteSnapshot(f, h)
+			f.Close()
+		}
+		if err != nil {
+			logger.Error("cannot write snapshot", "file", exportfile, "err", err)
+			os.Exit(1)
+		}
+		if text {
+			fmt.Printf("--- Snapshot of height %d written to %s (root)%s\n", h, exportfile, mysim.Block(h).Root().Hex())
+		}
+	}
+
+	if recordfile != "" {
+		f, err := os.Create(recordfile)
+		if err == nil {
+			_, err = recorder.WriteTo(f)
+			f.Close()
+		}
+		if err != nil {
+			logger.Error("cannot write transcript", "file", recordfile, "err", err)
+			os.Exit(1)
+		}
+	}
+
+	if timing && text {
 		bls.PrintCtrs()
 		fmt.Println("--- Info")
 		fmt.Println("Expected Crypto-Ops:")
 		fmt.Println("  Seckey calls:    m*n/m*n^2, m*n^2/m*n^2*k")
-		fmt.Println("  Pubkey calls:    N+m*n+m*n^2, m*n^2/m*n^2*k, m/m*n   (if --vvec enabled)")
-		// pubkey generation: N is process generation, m*n is vvec generation, m*n^2 is rhs of vvec verification
+		fmt.Println("  Pubkey calls:    N+m*n*k+m*n^2, m*n^2/m*n^2*k, m*k/m*k*n   (if --vvec enabled)")
+		// pubkey generation: N is process generation, m*n*k is vvec generation, m*n^2 is rhs of vvec verification
 		// pubkey sharing: m*n^2/m*n^2*k is lhs of vvec verification
-		// pubkey aggregation: m/m*n is generation of group pubkey from member shares
-		fmt.Println("  Pubkey calls:    N, 0/0, m/m*n                       (if --vvec disabled)")
+		// pubkey aggregation: m*k/m*k*n is combination of the members' vvecs into the group vvec
+		fmt.Println("  Pubkey calls:    N+m*n*k, 0/0, m*k/m*k*n                   (if --vvec disabled)")
 		fmt.Println("  Signature calls: N+l*n, N, l/l*k")
 	}
 }
+
+// startFromSnapshot -- a simulator that continues from the snapshot in file, which has to match the trusted root
+func startFromSnapshot(seed bls.Rand, file string, root string, cfg sim.Config) (sim.BlockchainSimulator, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(root, "0x"))
+	if err != nil || len(b) != common.HashLength {
+		return sim.BlockchainSimulator{}, fmt.Errorf("bad state root %q", root)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return sim.BlockchainSimulator{}, err
+	}
+	defer f.Close()
+	s, h, err := state.ReadSnapshot(f, common.BytesToHash(b))
+	if err != nil {
+		return sim.BlockchainSimulator{}, err
+	}
+	return sim.NewBlockchainSimulatorFromSnapshot(seed, s, h, cfg)
+}
+
+// bench -- sweep curves, group sizes and thresholds and write the measured costs as csv
+func bench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	curves := fs.String("curves", "bn254,bn382_1,bn382_2", "Comma-separated list of curves")
+	sizes := fs.String("n", "3,10,50", "Comma-separated list of group sizes")
+	thresholds := fs.String("k", "", "Comma-separated list of thresholds (default n/2+1 for each n)")
+	rounds := fs.Uint("l", 20, "Number of blocks for chain verification")
+	out := fs.String("out", "", "Write the csv table to this file instead of stdout")
+	fs.Parse(args)
+
+	ns, err := parseUints(*sizes)
+	if err != nil {
+		fmt.Printf("bad group sizes %s\n", *sizes)
+		os.Exit(2)
+	}
+	ks, err := parseUints(*thresholds)
+	if err != nil {
+		fmt.Printf("bad thresholds %s\n", *thresholds)
+		os.Exit(2)
+	}
+
+	var results []sim.BenchResult
+	for _, curve := range strings.Split(*curves, ",") {
+		c, ok := blscgo.Curves[curve]
+		if !ok {
+			fmt.Printf("not supported curve %s\n", curve)
+			os.Exit(2)
+		}
+		blscgo.Init(c)
+		for _, n := range ns {
+			kn := ks
+			if len(kn) == 0 {
+				kn = []uint16{n/2 + 1}
+			}
+			for _, k := range kn {
+				if k < 1 || k > n {
+					continue
+				}
+				res, err := sim.Bench(sim.BenchParams{Curve: curve, GroupSize: n, Threshold: k, Rounds: *rounds})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "bench %s n=%d k=%d failed: %v\n", curve, n, k, err)
+					os.Exit(1)
+				}
+				results = append(results, res)
+			}
+		}
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer w.Close()
+	}
+	if err := sim.WriteBenchCSV(w, results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// timelockCmd -- encrypt a file to a round of a simulated chain, or decrypt it once the chain has reached that round
+// The chain is simulated from the seed and parameters, which have to be the same for encryption and decryption.
+func timelockCmd(args []string) {
+	if len(args) == 0 || (args[0] != "encrypt" && args[0] != "decrypt") {
+		fmt.Println("usage: timelock encrypt|decrypt [flags]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("timelock "+args[0], flag.ExitOnError)
+	n := fs.Uint("n", 3, "Group size")
+	k := fs.Uint("k", 2, "Threshold")
+	N := fs.Uint("N", 8, "Number of processes")
+	m := fs.Uint("m", 5, "Number of groups")
+	seedstr := fs.String("seed", "DFINITY", "Random seed")
+	curve := fs.String("curve", "bn382_1", "Pairing type")
+	at := fs.Uint("at", 1, "Height of the latest block when encrypting, the ciphertext targets the next round (1 is the genesis block)")
+	in := fs.String("in", "", "Input file")
+	out := fs.String("out", "", "Output file")
+	fs.Parse(args[1:])
+	if *in == "" || *out == "" {
+		fmt.Println("-in and -out are required")
+		os.Exit(2)
+	}
+
+	sim.Format = sim.FormatNone
+	logger := dfn.NewTextLogger(os.Stderr, false)
+	bls.SetLogger(logger)
+	state.SetLogger(logger)
+	sim.SetLogger(logger)
+	c, ok := blscgo.Curves[*curve]
+	if !ok {
+		fmt.Printf("not supported curve %s\n", *curve)
+		os.Exit(2)
+	}
+	blscgo.Init(c)
+
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "timelock %s failed: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	data, err := ioutil.ReadFile(*in)
+	if err != nil {
+		fail(err)
+	}
+	var ct timelock.Ciphertext
+	height := int(*at)
+	if args[0] == "decrypt" {
+		if ct, err = timelock.Read(bytes.NewReader(data)); err != nil {
+			fail(err)
+		}
+		height = ct.Height
+	}
+	mysim, err := sim.NewBlockchainSimulator(bls.RandFromBytes([]byte(*seedstr)), uint16(*n), uint16(*k), *N, uint16(*m), sim.Config{})
+	if err == nil && height < mysim.Length() {
+		err = fmt.Errorf("no round before height %d", mysim.Length())
+	}
+	if err == nil {
+		err = mysim.Advance(uint(height-mysim.Length()), false)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if args[0] == "encrypt" {
+		round := timelock.NextRound(mysim.Tip(), mysim.Length())
+		if ct, err = timelock.Encrypt(round, data); err != nil {
+			fail(err)
+		}
+		var buf bytes.Buffer
+		if err = ct.Write(&buf); err == nil {
+			err = ioutil.WriteFile(*out, buf.Bytes(), 0644)
+		}
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("--- Encrypted %s to round %d (grp)%s\n", *in, round.Height, mysim.Tip().SelectedGroupAddress().Hex())
+		return
+	}
+	plain, err := timelock.Decrypt(ct, mysim.Tip().Signature())
+	if err == nil {
+		err = ioutil.WriteFile(*out, plain, 0644)
+	}
+	if err != nil {
+		fail(err)
+	}
+	fmt.Printf("--- Decrypted %s with the output of round %d\n", *in, ct.Height)
+}
+
+// parseUints -- parse a comma-separated list of numbers, empty for an empty string
+func parseUints(s string) (l []uint16, err error) {
+	if s == "" {
+		return
+	}
+	for _, f := range strings.Split(s, ",") {
+		i, err := strconv.ParseUint(strings.TrimSpace(f), 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, uint16(i))
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package sim
+
+import (
+	"dfinity/beacon/bls"
+	"dfinity/beacon/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Application signatures
+//
+// Clients submit application messages with a domain tag (RequestSignature). The group selected for the next
+// block is responsible for them: it threshold-signs state.AppMessage(domain, msg) in the same round as the
+// beacon output. A response is verified with State.VerifyAppSig against the state before its block, in which
+// the responsible group is registered.
+
+// SigningResponse -- the answer to a signing request
+type SigningResponse struct {
+	// height of the block in whose round the message was signed
+	Height int
+	// the responsible group, registered in the state at Height-1
+	Group common.Address
+	Sig   bls.Signature
+	Err   error
+}
+
+// AppSignatureRecord -- a signed application message, with the hash of the message
+type AppSignatureRecord struct {
+	Type      string `json:"type"`
+	ID        int    `json:"id"`
+	Height    int    `json:"height"`
+	Group     string `json:"grp"`
+	Domain    string `json:"domain"`
+	Msg       string `json:"msg"`
+	Signature string `json:"sig"`
+}
+
+// signingRequest -- a pending request
+type signingRequest struct {
+	id     int
+	domain string
+	msg    []byte
+}
+
+// RequestSignature -- submit an application message for signing in the next round, returns the ID of the request
+// IDs are shared with decryption requests. The domain must not be empty.
+func (sim *BlockchainSimulator) RequestSignature(domain string, msg []byte) (int, error) {
+	if domain == "" {
+		return 0, ErrNoDomain
+	}
+	id := sim.requests
+	sim.requests++
+	sim.signing = append(sim.signing, signingRequest{id, domain, append([]byte{}, msg...)})
+	if sim.signed == nil {
+		sim.signed = make(map[int]SigningResponse)
+	}
+	return id, nil
+}
+
+// SignedMessage -- the response to a signing request, false while the request is pending
+func (sim *BlockchainSimulator) SignedMessage(id int) (SigningResponse, bool) {
+	r, ok := sim.signed[id]
+	return r, ok
+}
+
+// signMessages -- let the group g, registered under a and selected by tip, sign all pending messages
+func (sim *BlockchainSimulator) signMessages(g *GroupSimulator, a common.Address, tip state.State) error {
+	height := sim.Length()
+	for _, req := range sim.signing {
+		sig, _, err := g.sign(state.AppMessage(req.domain, req.msg))
+		if err != nil {
+			return err
+		}
+		if DoubleCheck && !tip.VerifyAppSig(a, req.domain, req.msg, sig) {
+			logger.Error("application signature not valid", "height", height, "grp", a.Hex(), "id", req.id)
+			return ErrInvalidSignature
+		}
+		sim.signed[req.id] = SigningResponse{Height: height, Group: a, Sig: sig}
+		Emit(AppSignatureRecord{"appsig", req.id, height, a.Hex(), req.domain, crypto.Keccak256Hash(req.msg).Hex(), sig.String()})
+	}
+	sim.signing = nil
+	return nil
+}
@@ -0,0 +1,47 @@
+package sim
+
+import (
+	"dfinity/beacon/bls"
+	"dfinity/beacon/state"
+	"testing"
+)
+
+func TestSigningRequests(t *testing.T) {
+	sim := newTestSimulator(t, "appsig", 3, Config{})
+	if _, err := sim.RequestSignature("", []byte("x")); err != ErrNoDomain {
+		t.Error("Accepted a message without domain")
+	}
+	msg := []byte("attestation")
+	id1, _ := sim.RequestSignature("bridge", msg)
+	id2, _ := sim.RequestSignature("oracle", msg)
+	if _, ok := sim.SignedMessage(id1); ok {
+		t.Error("Request answered before the next block")
+	}
+	if err := sim.Advance(2, false); err != nil {
+		t.Fatal(err)
+	}
+	r1, ok1 := sim.SignedMessage(id1)
+	r2, ok2 := sim.SignedMessage(id2)
+	if !ok1 || !ok2 || r1.Err != nil || r1.Height != 2 {
+		t.Fatalf("Wrong responses %+v %+v", r1, r2)
+	}
+
+	// signed by the group that produced the beacon output of the block, verifiable against the state before it
+	prev := sim.Block(r1.Height - 1)
+	if signer, _ := sim.History().SignerAt(r1.Height); signer != r1.Group {
+		t.Error("Message not signed by the beacon group")
+	}
+	if !prev.VerifyAppSig(r1.Group, "bridge", msg, r1.Sig) || !prev.VerifyAppSig(r2.Group, "oracle", msg, r2.Sig) {
+		t.Error("Application signature does not verify")
+	}
+	// the signature is bound to its domain and is no beacon output
+	if prev.VerifyAppSig(r1.Group, "oracle", msg, r1.Sig) {
+		t.Error("Application signature verifies in another domain")
+	}
+	if bls.VerifySig(prev.GroupPubkey(r1.Group), msg, r1.Sig) || r1.Sig.String() == sim.Block(r1.Height).Signature().String() {
+		t.Error("Application signature is a signature on the raw message or the beacon output")
+	}
+	if string(state.AppMessage("a", []byte("bc"))) == string(state.AppMessage("ab", []byte("c"))) {
+		t.Error("Domain and message are not separated")
+	}
+}
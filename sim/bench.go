@@ -0,0 +1,152 @@
+package sim
+
+import (
+	"dfinity/beacon/bls"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// BenchParams -- one configuration of a benchmark sweep
+type BenchParams struct {
+	Curve     string
+	GroupSize uint16
+	Threshold uint16
+	Rounds    uint
+}
+
+// BenchResult -- the measured costs for one configuration
+type BenchResult struct {
+	BenchParams
+	DKG         time.Duration // setup of one group, all members dealing and verifying shares
+	SignShare   time.Duration // one signature share
+	VerifyShare time.Duration // one signature share against the member's pubkey share
+	Recover     time.Duration // one group signature from k shares
+	VerifyRound time.Duration // one round of chain verification
+}
+
+// benchHeader -- column names of the csv output
+var benchHeader = []string{"curve", "n", "k", "dkg_ns", "dkg_member_ns", "sign_share_ns", "verify_share_ns", "recover_ns", "verify_round_ns"}
+
+// benchReps -- number of repetitions of the recovery measurement
+const benchReps = 10
+
+// benchSeed --
+func benchSeed(p BenchParams) bls.Rand {
+	return bls.RandFromBytes([]byte(fmt.Sprintf("bench %s %d %d", p.Curve, p.GroupSize, p.Threshold)))
+}
+
+// benchGroup -- create n processes and run the DKG for one group of all of them
+func benchGroup(seed bls.Rand, n uint16, k uint16) (*GroupSimulator, time.Duration, error) {
+	procs := make([]ProcessSimulator, n)
+	members := make([]*ProcessSimulator, n)
+	var err error
+	for i := range procs {
+		procs[i], err = NewProcessSimulator(bls.SeckeyFromRand(seed.Ders("sec").Deri(i)), seed.Ders("seed").Deri(i))
+		if err != nil {
+			return nil, 0, err
+		}
+		members[i] = &procs[i]
+	}
+	t0 := time.Now()
+	g, err := NewGroupSimulator(members, k, 0, 0)
+	return &g, time.Since(t0), err
+}
+
+// Bench -- measure the cost of group setup, signing, verification and recovery for one configuration
+// The curve p.Curve has to be initialized by the caller.
+func Bench(p BenchParams) (res BenchResult, err error) {
+	res.BenchParams = p
+	// the simulator's own checks and output would distort the measurements
+	defer func(d, v bool, f string) { DoubleCheck, Vvec, Format = d, v, f }(DoubleCheck, Vvec, Format)
+	DoubleCheck, Vvec, Format = false, true, FormatNone
+
+	seed := benchSeed(p)
+	g, dkg, err := benchGroup(seed, p.GroupSize, p.Threshold)
+	if err != nil {
+		return
+	}
+	res.DKG = dkg
+
+	// signature shares
+	msg := seed.Bytes()
+	sigmap := bls.SignatureMap{}
+	t0 := time.Now()
+	for _, q := range g.proclist {
+		sigmap[q.Address()], err = q.SignForGroup(g.reginfo, msg)
+		if err != nil {
+			return
+		}
+	}
+	res.SignShare = time.Since(t0) / time.Duration(len(g.proclist))
+
+	// verification of signature shares
+	pubs := make([]bls.Pubkey, len(g.proclist))
+	for i, q := range g.proclist {
+		pubs[i], err = bls.PubkeyFromSeckey(q.GetAggregatedGroupShare(g.reginfo))
+		if err != nil {
+			return
+		}
+	}
+	t0 = time.Now()
+	for i, q := range g.proclist {
+		if !bls.VerifySig(pubs[i], msg, sigmap[q.Address()]) {
+			return res, ErrInvalidSignature
+		}
+	}
+	res.VerifyShare = time.Since(t0) / time.Duration(len(g.proclist))
+
+	// recovery
+	t0 = time.Now()
+	for i := 0; i < benchReps; i++ {
+		if _, err = recoverSignature(g.reginfo, sigmap); err != nil {
+			return
+		}
+	}
+	res.Recover = time.Since(t0) / benchReps
+
+	// chain verification
+	if p.Rounds > 0 {
+		sim, err := NewBlockchainSimulator(seed, p.GroupSize, p.Threshold, uint(p.GroupSize), 1, Config{})
+		if err != nil {
+			return res, err
+		}
+		if err = sim.Advance(p.Rounds, false); err != nil {
+			return res, err
+		}
+		t0 = time.Now()
+		if err = sim.VerifyChain(); err != nil {
+			return res, err
+		}
+		res.VerifyRound = time.Since(t0) / time.Duration(p.Rounds)
+	}
+	return
+}
+
+// WriteBenchCSV -- write benchmark results as a csv table with header
+func WriteBenchCSV(w io.Writer, results []BenchResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(benchHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		row := []string{
+			r.Curve,
+			strconv.Itoa(int(r.GroupSize)),
+			strconv.Itoa(int(r.Threshold)),
+			strconv.FormatInt(r.DKG.Nanoseconds(), 10),
+			strconv.FormatInt(r.DKG.Nanoseconds()/int64(r.GroupSize), 10),
+			strconv.FormatInt(r.SignShare.Nanoseconds(), 10),
+			strconv.FormatInt(r.VerifyShare.Nanoseconds(), 10),
+			strconv.FormatInt(r.Recover.Nanoseconds(), 10),
+			strconv.FormatInt(r.VerifyRound.Nanoseconds(), 10),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,139 @@
+package sim
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var benchCurves = []string{"bn254", "bn382_1", "bn382_2"}
+
+var benchSizes = []struct{ n, k uint16 }{{10, 6}, {50, 26}, {100, 51}}
+
+func TestBench(t *testing.T) {
+	res, err := Bench(BenchParams{"bn254", 5, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteBenchCSV(&buf, []BenchResult{res}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "bn254,5,3,") {
+		t.Error("Unexpected csv output", lines)
+	}
+}
+
+func benchmarkDKG(n uint16, k uint16, b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, _, err := benchGroup(bls.RandFromBytes([]byte{byte(i)}), n, k); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchChainLength -- number of blocks verified per op in BenchmarkVerifyChain
+const benchChainLength = 20
+
+func benchmarkVerifyChain(n uint16, k uint16, b *testing.B) {
+	b.StopTimer()
+	sim, err := NewBlockchainSimulator(bls.RandFromBytes([]byte("bench")), n, k, uint(n), 1, Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := sim.Advance(benchChainLength, false); err != nil {
+		b.Fatal(err)
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if err := sim.VerifyChain(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchSigShares -- a group of size n with threshold k and the signature shares of all members on msg
+func benchSigShares(n uint16, k uint16, msg []byte, b *testing.B) (*GroupSimulator, bls.SignatureMap) {
+	g, _, err := benchGroup(bls.RandFromBytes([]byte("bench")), n, k)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sigmap := bls.SignatureMap{}
+	for _, q := range g.proclist {
+		if sigmap[q.Address()], err = q.SignForGroup(g.reginfo, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return g, sigmap
+}
+
+func benchmarkSignShare(n uint16, k uint16, b *testing.B) {
+	b.StopTimer()
+	msg := []byte("bench")
+	g, _ := benchSigShares(n, k, msg, b)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := g.proclist[i%len(g.proclist)].SignForGroup(g.reginfo, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkVerifyShare(n uint16, k uint16, b *testing.B) {
+	b.StopTimer()
+	msg := []byte("bench")
+	g, sigmap := benchSigShares(n, k, msg, b)
+	pubs := make([]bls.Pubkey, len(g.proclist))
+	for i, q := range g.proclist {
+		var err error
+		if pubs[i], err = bls.PubkeyFromSeckey(q.GetAggregatedGroupShare(g.reginfo)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		j := i % len(g.proclist)
+		if !bls.VerifySig(pubs[j], msg, sigmap[g.proclist[j].Address()]) {
+			b.Fatal(ErrInvalidSignature)
+		}
+	}
+}
+
+func benchmarkRecover(n uint16, k uint16, b *testing.B) {
+	b.StopTimer()
+	g, sigmap := benchSigShares(n, k, []byte("bench"), b)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := recoverSignature(g.reginfo, sigmap); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkSweep -- run f for all curves and sizes, with the simulator's checks and output off as in Bench
+// The settings and the curve are restored afterwards.
+func benchmarkSweep(b *testing.B, name string, f func(uint16, uint16, *testing.B)) {
+	defer func(f string, d, v bool) { Format, DoubleCheck, Vvec = f, d, v }(Format, DoubleCheck, Vvec)
+	Format, DoubleCheck, Vvec = FormatNone, false, true
+	if c := blscgo.Curve(); c >= 0 {
+		defer blscgo.Init(c)
+	}
+	for _, curve := range benchCurves {
+		for _, s := range benchSizes {
+			b.Run(fmt.Sprintf("%s/%s/n=%d/k=%d", name, curve, s.n, s.k), func(b *testing.B) {
+				blscgo.Init(blscgo.Curves[curve])
+				f(s.n, s.k, b)
+			})
+		}
+	}
+}
+
+func BenchmarkDKG(b *testing.B)         { benchmarkSweep(b, "DKG", benchmarkDKG) }
+func BenchmarkSignShare(b *testing.B)   { benchmarkSweep(b, "SignShare", benchmarkSignShare) }
+func BenchmarkVerifyShare(b *testing.B) { benchmarkSweep(b, "VerifyShare", benchmarkVerifyShare) }
+func BenchmarkRecover(b *testing.B)     { benchmarkSweep(b, "Recover", benchmarkRecover) }
+func BenchmarkVerifyChain(b *testing.B) { benchmarkSweep(b, "VerifyChain20", benchmarkVerifyChain) }
@@ -1,8 +1,10 @@
 package sim
 
 import (
+	"bytes"
 	"dfinity/beacon/bls"
 	"dfinity/beacon/state"
+	"encoding/hex"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -12,10 +14,43 @@ type BlockchainSimulator struct {
 	groupSize uint16
 	threshold uint16
 	seed      bls.Rand
+	cfg       Config
 	proc      []ProcessSimulator
-	group     []GroupSimulator
+	group     []*GroupSimulator
 	grpmap    map[common.Address]*GroupSimulator
-	chain     []state.State
+	// the chain from the genesis block or from a snapshot
+	chain *state.History
+	// changes to be included in the next block, nil if there are none
+	next *state.Builder
+	// addresses of groups that were handed over, they are removed from grpmap once the next block is built
+	retired []common.Address
+	// notarized blocks, nil unless Notarize is set, and the notarizations delayed to the next round
+	forks *Forks
+	late  []NotarizedBlock
+	// requests by clients: the number submitted, the pending ones and the responses by ID
+	requests  int
+	pending   []decryptionRequest
+	decrypted map[int]DecryptionResponse
+	signing   []signingRequest
+	signed    map[int]SigningResponse
+}
+
+// Config -- the options of a simulation run, the zero value runs the plain beacon
+type Config struct {
+	// how the IDs for secret sharing are assigned to group members
+	IDMode state.IDMode
+	// refresh the shares of all groups every RefreshInterval blocks, 0 disables refreshes
+	RefreshInterval uint
+	// number of highest-ranked nodes that propose a candidate for each block, 0 disables proposals
+	Proposers uint
+	// enable notarization of candidate blocks and fork choice
+	Notarize bool
+	// percentage of rounds in which the notarization of the best candidate is delayed by one round
+	NotaryDelay uint
+	// form a new group every FormInterval blocks, 0 disables group formation
+	FormInterval uint
+	// if set, every cryptographic value produced by the simulation is appended to it
+	Recorder *Transcript
 }
 
 // DoubleCheck -- enable optional double-checks for verification
@@ -28,19 +63,29 @@ var Vvec = true
 var Timing = false
 
 // InitProcs -- initialize the individual processes for the genesis block
-func (sim *BlockchainSimulator) InitProcs(n uint) {
+func (sim *BlockchainSimulator) InitProcs(n uint) (err error) {
 	sim.proc = make([]ProcessSimulator, n)
 	rsec := sim.seed.Ders("InitProcs_sec")
 	rseed := sim.seed.Ders("InitProcs_seed")
 	for i := 0; i < int(n); i++ {
-		sim.proc[i] = NewProcessSimulator(bls.SeckeyFromRand(rsec.Deri(i)), rseed.Deri(i))
-		fmt.Println(sim.proc[i].String())
+		sim.proc[i], err = NewProcessSimulator(bls.SeckeyFromRand(rsec.Deri(i)), rseed.Deri(i))
+		if err != nil {
+			return
+		}
+		sim.proc[i].rec = sim.cfg.Recorder
+		sim.cfg.Recorder.record("proc", sim.proc[i].Address().Hex(), sim.proc[i].reginfo.Pubkey().String())
+		if Structured() {
+			Emit(sim.proc[i].Record())
+		} else {
+			fmt.Println(sim.proc[i].String())
+		}
 	}
+	return
 }
 
 // InitGroups -- initialize the groups for the genesis block
-func (sim *BlockchainSimulator) InitGroups(n uint16) {
-	sim.group = make([]GroupSimulator, n)
+func (sim *BlockchainSimulator) InitGroups(n uint16) (err error) {
+	sim.group = make([]*GroupSimulator, n)
 	sim.grpmap = make(map[common.Address]*GroupSimulator)
 	r := sim.seed.Ders("InitGroups")
 	// build a temporary state datastructure from processes
@@ -51,7 +96,7 @@ func (sim *BlockchainSimulator) InitGroups(n uint16) {
 	// create n groups
 	for i := 0; i < int(n); i++ {
 		// choose members based on r
-		/* groupinfo := s.NewRandomGroup(r.Deri(i), sim.groupSize)
+		/* groupinfo := s.NewRandomGroup(r.Deri(i), sim.groupSize, sim.threshold, IDMode, 0, uint64(i))
 		   groupinfo.Log() */
 		// LATER: replace the following using groupinfo
 		indices := r.Deri(i).RandomPerm(len(sim.proc), int(sim.groupSize))
@@ -59,78 +104,329 @@ func (sim *BlockchainSimulator) InitGroups(n uint16) {
 		for j, idx := range indices {
 			members[j] = &(sim.proc[idx])
 		}
-		sim.group[i] = NewGroupSimulator(members, sim.threshold)
-		sim.grpmap[sim.group[i].Address()] = &sim.group[i]
-		fmt.Println(sim.group[i].String())
+		// all genesis groups are formed at height 0, the index tells them apart
+		g, err := newGroupSimulator(members, sim.threshold, sim.cfg.IDMode, 0, uint64(i), sim.cfg.Recorder)
+		if err != nil {
+			return err
+		}
+		sim.group[i] = &g
+		sim.grpmap[g.Address()] = &g
+		if Structured() {
+			Emit(sim.group[i].Record())
+		} else {
+			fmt.Println(sim.group[i].String())
+		}
 	}
+	return
 }
 
 // NewBlockchainSimulator -- create a new blockchain simulation
-// set the seed and define parameters like group size, threshold, number of processes etc.
-func NewBlockchainSimulator(seed bls.Rand, groupSize uint16, threshold uint16, nProcesses uint, nGroups uint16) BlockchainSimulator {
-	sim := BlockchainSimulator{seed: seed, groupSize: groupSize, threshold: threshold}
-	sim.Log()
+// set the seed and define parameters like group size, threshold, number of processes etc., cfg holds the options
+func NewBlockchainSimulator(seed bls.Rand, groupSize uint16, threshold uint16, nProcesses uint, nGroups uint16, cfg Config) (BlockchainSimulator, error) {
+	sim := BlockchainSimulator{seed: seed, groupSize: groupSize, threshold: threshold, cfg: cfg}
+	if !Structured() {
+		sim.Log()
+	}
 
 	// Start the processes first
-	fmt.Printf("--- Process setup: (N)%d\n", nProcesses)
-	sim.InitProcs(nProcesses)
+	if !Structured() {
+		fmt.Printf("--- Process setup: (N)%d\n", nProcesses)
+	}
+	if err := sim.InitProcs(nProcesses); err != nil {
+		return sim, err
+	}
 
 	// Start the groups
-	fmt.Printf("--- Group setup: (m)%d\n", nGroups)
-	sim.InitGroups(nGroups)
+	if !Structured() {
+		fmt.Printf("--- Group setup: (m)%d\n", nGroups)
+	}
+	if err := sim.InitGroups(nGroups); err != nil {
+		return sim, err
+	}
 
 	// Build the genesis block
-	genesis := state.NewState()
+	b := state.NewBuilder(state.NewState())
 	for _, p := range sim.proc {
-		genesis.AddNode(p.reginfo)
 		// this includes verification of proof-of-possession
+		if err := b.AddNode(p.reginfo); err != nil {
+			return sim, err
+		}
 	}
 	for _, g := range sim.group {
-		genesis.AddGroup(g.reginfo)
+		if err := b.AddGroup(g.reginfo); err != nil {
+			return sim, err
+		}
 	}
 	// the sig field remains empty because the genesis block is not signed
+	genesis := b.Build()
 
 	// print op counts
-	if Timing {
+	if Timing && !Structured() {
 		bls.PrintCtrs()
 	}
 
 	// Build the chain with 1 block
-	sim.chain = append(sim.chain, genesis)
+	sim.chain = state.NewHistory(genesis, 1)
+	if sim.cfg.Notarize {
+		sim.forks = NewForks(NotarizedBlock{Height: 1, Root: genesis.Root()})
+	}
+	Emit(BlockRecord{Type: "block", Height: sim.Length(), StateRecord: genesis.Record()})
+
+	return sim, nil
+}
+
+// NewBlockchainSimulatorFromSnapshot -- start a simulation from the state s of the block at the given height
+// The secret keys of the processes and groups are not part of the state, they are derived from the seed
+// of the original run as in NewBlockchainSimulator, and so are the refreshes: they are replayed until the
+// verification vector matches the snapshot, cfg has to be the configuration of the original run. This works
+// for all groups formed by key generation, but not for groups that received their key in a handover.
+func NewBlockchainSimulatorFromSnapshot(seed bls.Rand, s state.State, height int, cfg Config) (BlockchainSimulator, error) {
+	sim := BlockchainSimulator{seed: seed, cfg: cfg}
+	nodes := s.NodeAddressList()
+	if !Structured() {
+		fmt.Printf("--- Process setup from snapshot: (N)%d\n", len(nodes))
+	}
+	if err := sim.InitProcs(uint(len(nodes))); err != nil {
+		return sim, err
+	}
+	procmap := make(map[common.Address]*ProcessSimulator, len(sim.proc))
+	for i := range sim.proc {
+		procmap[sim.proc[i].Address()] = &sim.proc[i]
+	}
+	for _, a := range nodes {
+		if _, ok := procmap[a]; !ok {
+			logger.Error("snapshot node not derived from seed", "proc", a.Hex())
+			return sim, ErrSnapshotKeys
+		}
+	}
+
+	groups := s.GroupAddressList()
+	if !Structured() {
+		fmt.Printf("--- Group setup from snapshot: (m)%d\n", len(groups))
+	}
+	sim.group = make([]*GroupSimulator, len(groups))
+	sim.grpmap = make(map[common.Address]*GroupSimulator)
+	for i, a := range groups {
+		reg, _ := s.Group(a)
+		addrs := reg.Members()
+		members := make([]*ProcessSimulator, len(addrs))
+		for j, m := range addrs {
+			if members[j] = procmap[m]; members[j] == nil {
+				logger.Error("snapshot group member not derived from seed", "grp", a.Hex(), "proc", m.Hex())
+				return sim, ErrSnapshotKeys
+			}
+		}
+		// the same group identity gives the same contributions to the group secret
+		g, err := newGroupSimulator(members, uint16(reg.Threshold()), reg.IDMode(), reg.Height(), reg.Nonce(), sim.cfg.Recorder)
+		if err != nil {
+			return sim, err
+		}
+		// the shares were refreshed at most once every RefreshInterval blocks before the snapshot
+		for e := 0; sim.cfg.RefreshInterval > 0 && e < (height-1)/int(sim.cfg.RefreshInterval) && g.reginfo.Hash() != reg.Hash(); e++ {
+			if err := g.Refresh(); err != nil {
+				return sim, err
+			}
+		}
+		if g.Address() != a || g.reginfo.Hash() != reg.Hash() {
+			logger.Error("snapshot group key not derived from seed", "grp", a.Hex())
+			return sim, ErrSnapshotKeys
+		}
+		sim.group[i] = &g
+		sim.grpmap[a] = &g
+		sim.groupSize, sim.threshold = uint16(reg.Size()), uint16(reg.Threshold())
+		if Structured() {
+			Emit(sim.group[i].Record())
+		} else {
+			fmt.Println(sim.group[i].String())
+		}
+	}
 
-	return sim
+	sim.chain = state.NewHistory(s, height)
+	if sim.cfg.Notarize {
+		sim.forks = NewForks(NotarizedBlock{Height: height, Root: s.Root()})
+	}
+	Emit(BlockRecord{Type: "block", Height: sim.Length(), StateRecord: s.Record()})
+	return sim, nil
 }
 
 // Advance -- carry out the simulation for the given number of steps (blocks)
-func (sim *BlockchainSimulator) Advance(n uint, verbose bool) {
+func (sim *BlockchainSimulator) Advance(n uint, verbose bool) error {
 	if n == 0 {
-		return
+		return nil
 	}
 	// choose tip
 	tip := sim.Tip()
 	// select pre-determined random group from tip
 	a := tip.SelectedGroupAddress()
-	g := sim.grpmap[a]
+	g, ok := sim.grpmap[a]
+	if !ok {
+		logger.Error("no simulator for selected group", "grp", a.Hex())
+		return ErrUnknownGroup
+	}
 	// get new group signature
-	sig := g.Sign(tip.Rand().Bytes())
+	sig, timing, err := g.sign(tip.Rand().Bytes())
+	if err != nil {
+		return err
+	}
 	if DoubleCheck {
 		if !bls.VerifySig(tip.GroupPubkey(a), tip.Rand().Bytes(), sig) {
-			fmt.Println("Error: group signature not valid.")
+			logger.Error("group signature not valid", "height", sim.Length()+1, "grp", a.Hex())
+			return ErrInvalidSignature
+		}
+		// the selected group is committed to by the tip's state root
+		if reg, _ := tip.Group(a); !state.VerifyGroupProof(tip.Root(), a, &reg, tip.ProveGroup(a)) {
+			logger.Error("group not proven by state root", "height", sim.Length(), "grp", a.Hex())
+			return ErrInvalidProof
+		}
+	}
+
+	// a new group is registered with the block
+	if sim.cfg.FormInterval > 0 && uint(sim.Length())%sim.cfg.FormInterval == 0 {
+		if err := sim.formRandomGroup(); err != nil {
+			return err
 		}
 	}
 
-	// the new state is identical to the curren tip, except that we overwrite the signature
-	newstate := tip
+	// the new state is derived from the current tip, with pending changes and the new signature
+	b := sim.nextBuilder()
+	sim.next = nil
 
 	// sign new state by group
-	newstate.SetSignature(sig)
+	b.SetSignature(sig)
+
+	// the highest-ranked nodes propose candidates, the best one notarized in time becomes the block
+	var candidates []common.Address
+	inTime := 0
+	if sim.cfg.Proposers > 0 || sim.forks != nil {
+		if candidates, err = sim.propose(tip); err != nil {
+			return err
+		}
+		if sim.forks != nil && sim.notaryDelayed(tip, len(candidates)) {
+			inTime = 1
+		}
+		b.SetProposer(candidates[inTime])
+	}
+
+	// refresh the shares of all groups, the new state records their new verification vectors
+	if sim.cfg.RefreshInterval > 0 && uint(sim.Length())%sim.cfg.RefreshInterval == 0 {
+		if err := sim.Refresh(b); err != nil {
+			return err
+		}
+	}
+	newstate := b.Build()
+	for _, r := range sim.retired {
+		if g, ok := sim.grpmap[r]; ok && g.Address() != r {
+			delete(sim.grpmap, r)
+		}
+	}
+	sim.retired = nil
 
 	// append new state
-	sim.chain = append(sim.chain, newstate)
+	sim.chain.Append(newstate)
+	sim.cfg.Recorder.record("beacon", sim.Length(), a.Hex(), sig.String(), hex.EncodeToString(newstate.Rand().Bytes()))
+	Emit(BlockRecord{"block", sim.Length(), a.Hex(), newstate.Record(), timing})
+
+	// the selected group notarizes the candidates
+	if sim.forks != nil {
+		if err := sim.notarize(g, a, tip, newstate, candidates, inTime); err != nil {
+			return err
+		}
+	}
+
+	// the selected group signs the application messages submitted before the block
+	if len(sim.signing) > 0 {
+		if err := sim.signMessages(g, a, tip); err != nil {
+			return err
+		}
+	}
+
+	// the groups answer the decryption requests submitted before the block
+	if len(sim.pending) > 0 {
+		sim.decrypt()
+	}
 
 	// recurse
-	sim.Advance(n-1, verbose)
-	return
+	return sim.Advance(n-1, verbose)
+}
+
+// propose -- let the Proposers highest-ranked nodes of the tip's ranking propose candidates for the next block
+// Returns the proposers ordered by priority, at least one.
+func (sim *BlockchainSimulator) propose(tip state.State) ([]common.Address, error) {
+	ranking := tip.ProposerRanking()
+	n := sim.cfg.Proposers
+	if n == 0 {
+		n = 1
+	}
+	if uint(len(ranking)) > n {
+		ranking = ranking[:n]
+	}
+	var prev bls.Rand
+	for i, a := range ranking {
+		prio := state.Priority(tip.Rand(), a)
+		if DoubleCheck {
+			// the priority follows from the tip's random output and the inclusion proof of the proposer
+			node, _ := tip.Node(a)
+			if p, ok := state.VerifyProposer(tip.Root(), tip.Rand(), node, tip.ProveNode(a)); !ok || p != prio {
+				logger.Error("proposer not proven by state root", "height", sim.Length()+1, "proc", a.Hex())
+				return nil, ErrInvalidProof
+			}
+			if i > 0 && bytes.Compare(prev[:], prio[:]) >= 0 {
+				logger.Error("proposers not ranked by priority", "height", sim.Length()+1, "proc", a.Hex())
+				return nil, ErrInvalidProof
+			}
+		}
+		prev = prio
+		sim.cfg.Recorder.record("candidate", sim.Length()+1, i, a.Hex(), hex.EncodeToString(prio.Bytes()))
+		Emit(CandidateRecord{"candidate", sim.Length() + 1, i, a.Hex(), hex.EncodeToString(prio.Bytes())})
+	}
+	return ranking, nil
+}
+
+// nextBuilder -- the builder for the next block, derived from the tip
+func (sim *BlockchainSimulator) nextBuilder() *state.Builder {
+	if sim.next == nil {
+		sim.next = state.NewBuilder(sim.Tip())
+	}
+	return sim.next
+}
+
+// Refresh -- refresh the shares of all groups and update them in the state built by b
+func (sim *BlockchainSimulator) Refresh(b *state.Builder) error {
+	for i := range sim.group {
+		if err := sim.group[i].Refresh(); err != nil {
+			return err
+		}
+		if err := b.UpdateGroup(sim.group[i].reginfo); err != nil {
+			return err
+		}
+	}
+	logger.Debug("refreshed group shares", "height", sim.Length()+1, "m", len(sim.group))
+	return nil
+}
+
+// Handover -- hand the key of group a over to the processes with the given addresses and threshold k
+// The new group replaces the old one in the next block, which records its certificate. Until then the old
+// address still refers to the group simulator, whose new members sign under the unchanged group pubkey.
+func (sim *BlockchainSimulator) Handover(a common.Address, addrs []common.Address, k uint16) error {
+	g, ok := sim.grpmap[a]
+	if !ok {
+		logger.Error("no simulator for group", "grp", a.Hex())
+		return ErrUnknownGroup
+	}
+	members, err := sim.processes(addrs)
+	if err != nil {
+		return err
+	}
+	if err := g.Handover(members, k, uint64(sim.Length())); err != nil {
+		return err
+	}
+	sim.grpmap[g.Address()] = g
+	sim.retired = append(sim.retired, a)
+	b := sim.nextBuilder()
+	if err := b.ReplaceGroup(a, g.reginfo); err != nil {
+		return err
+	}
+	return sim.certify(b, g.reginfo)
 }
 
 // Log -- print out a short form of the current state of the random beacon
@@ -140,8 +436,8 @@ func (sim *BlockchainSimulator) Log() {
 	/*
 		fmt.Println("  groups: ", len(sim.group))
 		fmt.Println("  processes: ", len(sim.proc))
-		fmt.Println("  chain height: ", len(sim.chain))
-		sim.chain[len(sim.chain)-1].Log()
+		fmt.Println("  chain height: ", sim.Length())
+		sim.Tip().Log()
 		for _, p := range sim.proc {
 			p.Log()
 		}
@@ -153,10 +449,47 @@ func (sim *BlockchainSimulator) Log() {
 
 // Length -- return the current block height
 func (sim *BlockchainSimulator) Length() int {
-	return len(sim.chain)
+	return sim.chain.Height()
+}
+
+// Base -- the height of the first block held by the simulator, 1 unless it was started from a snapshot
+func (sim *BlockchainSimulator) Base() int {
+	return sim.chain.Base()
+}
+
+// Block -- return the state at the given height (1 is the genesis block), which must be at least Base()
+func (sim *BlockchainSimulator) Block(h int) state.State {
+	s, _ := sim.chain.At(h)
+	return s
+}
+
+// History -- the chain with its indices for historical queries
+func (sim *BlockchainSimulator) History() *state.History {
+	return sim.chain
+}
+
+// VerifyChain -- verify the group signature of every block against the group selected by its predecessor
+// All blocks are checked in one randomized batch. A simulator started from a snapshot verifies from the snapshot on.
+func (sim *BlockchainSimulator) VerifyChain() error {
+	base := sim.Base()
+	if sim.Length() <= base {
+		return nil
+	}
+	checks := make([]bls.SigCheck, sim.Length()-base)
+	for h := base + 1; h <= sim.Length(); h++ {
+		prev := sim.Block(h - 1)
+		checks[h-base-1] = bls.SigCheck{Pub: prev.SelectedGroupPubkey(), Msg: prev.Rand().Bytes(), Sig: sim.Block(h).Signature()}
+	}
+	if bad := bls.VerifyBatch(checks); bad != nil {
+		for _, i := range bad {
+			logger.Error("group signature not valid", "height", base+i+1, "grp", sim.Block(base+i).SelectedGroupAddress().Hex())
+		}
+		return ErrInvalidSignature
+	}
+	return nil
 }
 
 // Tip -- return the current state at the tip of the chain
 func (sim *BlockchainSimulator) Tip() state.State {
-	return sim.chain[len(sim.chain)-1]
+	return sim.chain.Tip()
 }
@@ -0,0 +1,70 @@
+package sim
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"dfinity/beacon/state"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	// the tests check results, the simulation output would only clutter theirs
+	Format = FormatNone
+	os.Exit(m.Run())
+}
+
+// newTestSimulator -- a simulation of 8 processes and m groups of 3 with threshold 2, seeded by name
+func newTestSimulator(t *testing.T, name string, m uint16, cfg Config) BlockchainSimulator {
+	sim, err := NewBlockchainSimulator(bls.RandFromBytes([]byte(name)), 3, 2, 8, m, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}
+
+func TestSnapshotSync(t *testing.T) {
+	full := newTestSimulator(t, "snapshot", 3, Config{RefreshInterval: 4})
+	if err := full.Advance(12, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// export after a refresh and continue from there
+	var buf bytes.Buffer
+	if err := full.Block(6).WriteSnapshot(&buf, 6); err != nil {
+		t.Fatal(err)
+	}
+	s, h, err := state.ReadSnapshot(&buf, full.Block(6).Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	synced, err := NewBlockchainSimulatorFromSnapshot(full.seed, s, h, full.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced.Base() != 6 || synced.Length() != 6 {
+		t.Fatal("Wrong height after sync", synced.Base(), synced.Length())
+	}
+	if err := synced.Advance(6, false); err != nil {
+		t.Fatal(err)
+	}
+	// the beacon is unique, so the synced chain continues exactly like the full one
+	for h := 7; h <= 12; h++ {
+		if synced.Block(h).Signature().String() != full.Block(h).Signature().String() || synced.Block(h).Root() != full.Block(h).Root() {
+			t.Fatal("Synced chain differs from full chain at height", h)
+		}
+	}
+	if err := synced.VerifyChain(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys cannot be derived with a different seed, nor the refreshed shares without the refreshes
+	if _, err := NewBlockchainSimulatorFromSnapshot(bls.RandFromBytes([]byte("other")), s, h, full.cfg); err != ErrSnapshotKeys {
+		t.Error("Expected ErrSnapshotKeys, got", err)
+	}
+	if _, err := NewBlockchainSimulatorFromSnapshot(full.seed, s, h, Config{}); err != ErrSnapshotKeys {
+		t.Error("Expected ErrSnapshotKeys without refreshes, got", err)
+	}
+}
@@ -0,0 +1,95 @@
+package sim
+
+import (
+	"dfinity/beacon/state"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Group formation and certification
+//
+// New groups run the key generation like the genesis groups and are registered with the next block. Every group
+// registered after the genesis block, formed or handed over, is certified by the group of the tip with the
+// shortest certificate chain, so the chains of all groups stay short.
+
+// FormGroup -- run the key generation for a new group of the given processes with threshold k
+// The group is registered and certified in the next block, returns its address.
+func (sim *BlockchainSimulator) FormGroup(addrs []common.Address, k uint16) (common.Address, error) {
+	members, err := sim.processes(addrs)
+	if err != nil {
+		return common.Address{}, err
+	}
+	// the number of groups tells groups formed at the same height apart
+	g, err := newGroupSimulator(members, k, sim.cfg.IDMode, uint64(sim.Length()), uint64(len(sim.group)), sim.cfg.Recorder)
+	if err != nil {
+		return common.Address{}, err
+	}
+	b := sim.nextBuilder()
+	if err := b.AddGroup(g.reginfo); err != nil {
+		return common.Address{}, err
+	}
+	if err := sim.certify(b, g.reginfo); err != nil {
+		return common.Address{}, err
+	}
+	sim.addGroup(&g)
+	if Structured() {
+		Emit(g.Record())
+	}
+	return g.Address(), nil
+}
+
+// formRandomGroup -- form a group of random processes, chosen by the tip's random output
+func (sim *BlockchainSimulator) formRandomGroup() error {
+	indices := sim.Tip().Rand().Ders("FormGroup").RandomPerm(len(sim.proc), int(sim.groupSize))
+	addrs := make([]common.Address, len(indices))
+	for i, idx := range indices {
+		addrs[i] = sim.proc[idx].Address()
+	}
+	_, err := sim.FormGroup(addrs, sim.threshold)
+	return err
+}
+
+// certify -- let the group of the tip with the shortest certificate chain certify g, registered by b
+func (sim *BlockchainSimulator) certify(b *state.Builder, g state.Group) error {
+	tip := sim.Tip()
+	var signer common.Address
+	depth := -1
+	for _, a := range tip.GroupAddressList() {
+		if chain, ok := sim.chain.CertificateChain(a); ok && (depth < 0 || len(chain) < depth) {
+			signer, depth = a, len(chain)
+		}
+	}
+	gs, ok := sim.grpmap[signer]
+	if depth < 0 || !ok {
+		logger.Error("no group to certify", "grp", g.Address().Hex())
+		return ErrUnknownGroup
+	}
+	sig, err := gs.Sign(state.CertificateMessage(g.Address(), g.Pubkey()))
+	if err != nil {
+		return err
+	}
+	sim.cfg.Recorder.record("cert", g.Address().Hex(), signer.Hex(), sig.String())
+	return b.Certify(state.Certificate{Group: g.Address(), Pubkey: g.Pubkey(), Signer: signer, Sig: sig})
+}
+
+// addGroup -- add a group simulator
+func (sim *BlockchainSimulator) addGroup(g *GroupSimulator) {
+	sim.group = append(sim.group, g)
+	sim.grpmap[g.Address()] = g
+}
+
+// processes -- the simulators of the processes with the given addresses
+func (sim *BlockchainSimulator) processes(addrs []common.Address) ([]*ProcessSimulator, error) {
+	members := make([]*ProcessSimulator, len(addrs))
+	for i, addr := range addrs {
+		for j := range sim.proc {
+			if sim.proc[j].Address() == addr {
+				members[i] = &sim.proc[j]
+			}
+		}
+		if members[i] == nil {
+			logger.Error("no simulator for process", "proc", addr.Hex())
+			return nil, ErrUnknownProcess
+		}
+	}
+	return members, nil
+}
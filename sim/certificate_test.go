@@ -0,0 +1,129 @@
+package sim
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/state"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestGroupCertificates(t *testing.T) {
+	sim := newTestSimulator(t, "certificates", 2, Config{FormInterval: 2})
+	if err := sim.Advance(6, false); err != nil {
+		t.Fatal(err)
+	}
+	// hand a formed group over, the new address is certified too
+	formed := sim.Tip().GroupAddressList()
+	var a common.Address
+	for _, x := range formed {
+		if c, ok := sim.History().Certificate(x); ok {
+			a = c.Group
+			break
+		}
+	}
+	g, _ := sim.Tip().Group(a)
+	// thresholds outside of 1..n are rejected
+	if err := sim.Handover(a, g.Members()[:2], 3); err != state.ErrInvalidGroup {
+		t.Error("Expected ErrInvalidGroup for k > n, got", err)
+	}
+	if _, err := sim.FormGroup(g.Members(), 0); err != state.ErrInvalidGroup {
+		t.Error("Expected ErrInvalidGroup for k = 0, got", err)
+	}
+	if err := sim.Handover(a, g.Members()[:2], 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Advance(1, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(sim.Tip().GroupAddressList()) != 5 || len(sim.Tip().Certificates()) != 1 {
+		t.Fatal("Wrong number of groups or certificates", len(sim.Tip().GroupAddressList()), len(sim.Tip().Certificates()))
+	}
+
+	// a light client verifies every group from the genesis keys
+	trusted := sim.Block(1).GroupPubkeys()
+	for _, x := range sim.Tip().GroupAddressList() {
+		chain, ok := sim.History().CertificateChain(x)
+		if !ok {
+			t.Fatalf("No certificate chain for %x", x[:2])
+		}
+		pub, ok := state.VerifyCertificateChain(trusted, x, chain)
+		if !ok || pub.String() != sim.Tip().GroupPubkey(x).String() {
+			t.Errorf("Certificate chain of %x does not verify", x[:2])
+		}
+		if _, genesis := trusted[x]; !genesis && len(chain) == 0 {
+			t.Errorf("Empty chain for new group %x", x[:2])
+		}
+		if len(chain) > 1 {
+			t.Errorf("Chain of %x is not the shortest, length %d", x[:2], len(chain))
+		}
+		if len(chain) == 0 {
+			continue
+		}
+		// a wrong key, a wrong signer or a missing trust anchor is detected
+		bad := append([]state.Certificate{}, chain...)
+		other, err := bls.PubkeyFromSeckey(bls.SeckeyFromInt(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bad[len(bad)-1].Pubkey = other
+		if _, ok := state.VerifyCertificateChain(trusted, x, bad); ok {
+			t.Error("Chain with a modified key verifies")
+		}
+		if _, ok := state.VerifyCertificateChain(bls.PubkeyMap{}, x, chain); ok {
+			t.Error("Chain verifies without trusted keys")
+		}
+	}
+
+	// a forged certificate is not recorded
+	b := state.NewBuilder(sim.Tip())
+	c := sim.Block(3).Certificates()[0]
+	c.Signer = formed[0]
+	if b.Certify(c) != state.ErrInvalidCertificate {
+		t.Error("Forged certificate accepted")
+	}
+
+	// formed groups can be re-derived from the seed after a snapshot
+	var buf bytes.Buffer
+	if err := sim.Block(6).WriteSnapshot(&buf, 6); err != nil {
+		t.Fatal(err)
+	}
+	s, h, err := state.ReadSnapshot(&buf, sim.Block(6).Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	synced, err := NewBlockchainSimulatorFromSnapshot(sim.seed, s, h, sim.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := synced.Advance(1, false); err != nil {
+		t.Fatal(err)
+	}
+	if synced.Block(7).Signature().String() != sim.Block(7).Signature().String() {
+		t.Error("Synced chain differs")
+	}
+}
+
+func TestFormWithRefreshAndNotarization(t *testing.T) {
+	sim := newTestSimulator(t, "form refresh", 2, Config{RefreshInterval: 2, Proposers: 2, Notarize: true, FormInterval: 2})
+	// the selected group notarizes and signs with its refreshed shares in blocks that also register a new group
+	var ids []int
+	for i := 0; i < 8; i++ {
+		id, err := sim.RequestSignature("test", []byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		if err := sim.Advance(1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range ids {
+		if r, ok := sim.SignedMessage(id); !ok || r.Err != nil {
+			t.Errorf("Wrong response %+v", r)
+		}
+	}
+	if len(sim.Tip().GroupAddressList()) != 6 || sim.Forks().Head().Height != sim.Length() {
+		t.Error("Groups not formed or blocks not notarized", len(sim.Tip().GroupAddressList()), sim.Forks().Head().Height)
+	}
+}
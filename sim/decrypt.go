@@ -0,0 +1,108 @@
+package sim
+
+import (
+	"dfinity/beacon/bls"
+	"dfinity/beacon/elgamal"
+	"dfinity/beacon/state"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Threshold decryption
+//
+// Clients encrypt to the pubkey of a registered group (package elgamal) and submit the ciphertext with
+// RequestDecryption. Requests are answered alongside the beacon: after the next block, all members of the
+// group publish decryption shares, which are checked against the group's verification vector, and the
+// first k valid ones decrypt. Requests are routed by the group pubkey, so they reach the successor of a group
+// that handed its key over. Requests to pubkeys that no group in the tip holds fail.
+
+// DecryptionResponse -- the answer to a decryption request
+type DecryptionResponse struct {
+	// height of the block after which the request was answered
+	Height  int
+	Payload []byte
+	Err     error
+}
+
+// DecryptionRecord -- an answered decryption request, with the hash of the payload
+type DecryptionRecord struct {
+	Type    string `json:"type"`
+	ID      int    `json:"id"`
+	Height  int    `json:"height"`
+	Group   string `json:"grp,omitempty"`
+	Shares  int    `json:"shares"`
+	Payload string `json:"payload,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// decryptionRequest -- a pending request
+type decryptionRequest struct {
+	id int
+	c  elgamal.Ciphertext
+}
+
+// RequestDecryption -- submit a ciphertext for decryption by its group, returns the ID of the request
+func (sim *BlockchainSimulator) RequestDecryption(c elgamal.Ciphertext) int {
+	id := sim.requests
+	sim.requests++
+	sim.pending = append(sim.pending, decryptionRequest{id, c})
+	if sim.decrypted == nil {
+		sim.decrypted = make(map[int]DecryptionResponse)
+	}
+	return id
+}
+
+// Decryption -- the response to a request, false while the request is pending
+func (sim *BlockchainSimulator) Decryption(id int) (DecryptionResponse, bool) {
+	r, ok := sim.decrypted[id]
+	return r, ok
+}
+
+// decrypt -- answer all pending requests after the block at the tip
+func (sim *BlockchainSimulator) decrypt() {
+	tip := sim.Tip()
+	for _, req := range sim.pending {
+		r, rec := sim.decryptOne(tip, req.c)
+		r.Height, rec.ID, rec.Height = sim.Length(), req.id, sim.Length()
+		if r.Err != nil {
+			logger.Debug("decryption failed", "id", req.id, "pub", req.c.Pubkey.String(), "err", r.Err)
+			rec.Error = r.Err.Error()
+		} else {
+			rec.Payload = crypto.Keccak256Hash(r.Payload).Hex()
+		}
+		sim.decrypted[req.id] = r
+		Emit(rec)
+	}
+	sim.pending = nil
+}
+
+// groupByPubkey -- the simulator of the group in the tip that holds the pubkey
+func (sim *BlockchainSimulator) groupByPubkey(tip state.State, pub bls.Pubkey) (*GroupSimulator, bool) {
+	for a, p := range tip.GroupPubkeys() {
+		if p.String() == pub.String() {
+			g, ok := sim.grpmap[a]
+			return g, ok
+		}
+	}
+	return nil, false
+}
+
+// decryptOne -- let the members of the group holding the ciphertext's pubkey produce decryption shares and combine them
+func (sim *BlockchainSimulator) decryptOne(tip state.State, c elgamal.Ciphertext) (DecryptionResponse, DecryptionRecord) {
+	rec := DecryptionRecord{Type: "decryption"}
+	g, ok := sim.groupByPubkey(tip, c.Pubkey)
+	if !ok {
+		return DecryptionResponse{Err: ErrUnknownGroup}, rec
+	}
+	rec.Group = g.Address().Hex()
+	shares := make([]elgamal.Share, 0, len(g.proclist))
+	for _, p := range g.proclist {
+		s, err := p.DecryptForGroup(g.reginfo, c)
+		if err != nil {
+			return DecryptionResponse{Err: err}, rec
+		}
+		shares = append(shares, s)
+	}
+	payload, used, err := elgamal.Combine(g.reginfo, c, shares)
+	rec.Shares = len(used)
+	return DecryptionResponse{Payload: payload, Err: err}, rec
+}
@@ -0,0 +1,77 @@
+package sim
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/elgamal"
+	"testing"
+)
+
+func TestDecryptionRequests(t *testing.T) {
+	sim := newTestSimulator(t, "decrypt", 3, Config{RefreshInterval: 2})
+	g, _ := sim.Tip().Group(sim.Tip().GroupAddressList()[0])
+	c, err := elgamal.Encrypt(g, []byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := sim.RequestDecryption(c)
+	// an unknown group cannot answer
+	unknown := c
+	if unknown.Pubkey, err = bls.PubkeyFromSeckey(bls.SeckeyFromInt(1)); err != nil {
+		t.Fatal(err)
+	}
+	bad := sim.RequestDecryption(unknown)
+	if _, ok := sim.Decryption(id); ok {
+		t.Error("Request answered before the next block")
+	}
+
+	// requests are answered after the next block, also after the shares were refreshed
+	if err := sim.Advance(3, false); err != nil {
+		t.Fatal(err)
+	}
+	r, ok := sim.Decryption(id)
+	if !ok || r.Err != nil || !bytes.Equal(r.Payload, []byte("first")) || r.Height != 2 {
+		t.Errorf("Wrong response %+v", r)
+	}
+	if r, ok := sim.Decryption(bad); !ok || r.Err != ErrUnknownGroup {
+		t.Errorf("Wrong response to unknown group %+v", r)
+	}
+	id = sim.RequestDecryption(c)
+	if err := sim.Advance(1, false); err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := sim.Decryption(id); !ok || r.Err != nil || !bytes.Equal(r.Payload, []byte("first")) {
+		t.Errorf("Wrong response after refresh %+v", r)
+	}
+}
+
+func TestDecryptionAfterHandover(t *testing.T) {
+	sim := newTestSimulator(t, "decrypt", 3, Config{})
+	a := sim.Tip().GroupAddressList()[0]
+	g, _ := sim.Tip().Group(a)
+	c, err := elgamal.Encrypt(g, []byte("handed over"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the ciphertext reaches the group under its new address, before and after the handover is included
+	before := sim.RequestDecryption(c)
+	if err := sim.Handover(a, g.Members()[1:], 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Advance(1, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sim.Tip().Group(a); ok {
+		t.Fatal("Handover not included in the block")
+	}
+	after := sim.RequestDecryption(c)
+	if err := sim.Advance(1, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{before, after} {
+		if r, ok := sim.Decryption(id); !ok || r.Err != nil || !bytes.Equal(r.Payload, []byte("handed over")) {
+			t.Errorf("Wrong response to request %d %+v", id, r)
+		}
+	}
+}
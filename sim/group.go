@@ -15,30 +15,56 @@ type GroupSimulator struct {
 	reginfo  state.Group
 	proclist []*ProcessSimulator
 	procmap  map[common.Address]*ProcessSimulator
+	// the members whose signature shares are used for recovery, with their precomputed basis
+	signers []common.Address
+	basis   bls.LagrangeBasis
+	// number of share refreshes so far
+	epoch int
+	// transcript of the run, nil if it is not recorded
+	rec *Transcript
 }
 
 // ExchangeSeckeyShares -- make all group members exchange secret shares with each other
-func ExchangeSeckeyShares(g state.Group, members []*ProcessSimulator) {
-	for _, p := range members {
+// Returns the combined verification vector of all members' contributions.
+func ExchangeSeckeyShares(g state.Group, members []*ProcessSimulator) (bls.VerificationVector, error) {
+	vvecs := make([]bls.VerificationVector, len(members))
+	for i, p := range members {
 		// get secret shares for all other processes
-		shares, vvec := p.GetSeckeySharesForGroup(g)
+		shares, vvec, err := p.GetSeckeySharesForGroup(g)
+		if err != nil {
+			return nil, err
+		}
+		vvecs[i] = vvec
 		// send shares out to all other individual processes
 		for _, q := range members {
-			q.SetGroupShare(g.Address(), p.Address(), shares[q.Address()], vvec)
+			if err := q.SetGroupShare(g, p.Address(), shares[q.Address()], vvec); err != nil {
+				return nil, err
+			}
 		}
 		// optional double-check of the group secret
 		if DoubleCheck {
 			sec := p.GetSeckeyForGroup(g)
-			recovered := bls.RecoverSeckeyByMap(shares, g.Threshold())
-			if sec.String() != recovered.String() {
-				fmt.Println("Error: recovered seckey share (ByMap) does not match.")
+			recovered, err := recoverSeckey(g, shares)
+			if err != nil {
+				return nil, err
+			}
+			if !sec.Equal(recovered) {
+				logger.Error("recovered seckey share (ByMap) does not match", "grp", g.Address().Hex(), "src", p.Address().Hex())
+				return nil, ErrSeckeyMismatch
 			}
 		}
 	}
+	return bls.CombineVerificationVectors(vvecs)
 }
 
 // NewGroupSimulator -- create a new group simulator, given simulators of its members
-func NewGroupSimulator(members []*ProcessSimulator, k uint16) GroupSimulator {
+// height and nonce become part of the group's identity (see state.NewGroup). Members get IDs by address.
+func NewGroupSimulator(members []*ProcessSimulator, k uint16, height uint64, nonce uint64) (GroupSimulator, error) {
+	return newGroupSimulator(members, k, state.IDByAddress, height, nonce, nil)
+}
+
+// newGroupSimulator -- NewGroupSimulator with the given IDMode, recording to rec
+func newGroupSimulator(members []*ProcessSimulator, k uint16, mode state.IDMode, height uint64, nonce uint64, rec *Transcript) (GroupSimulator, error) {
 	m := len(members)
 	// collect all members' addresses in a Group struct with empty Pubkey
 	addresses := make([]common.Address, m)
@@ -47,20 +73,23 @@ func NewGroupSimulator(members []*ProcessSimulator, k uint16) GroupSimulator {
 		addresses[i] = p.Address()
 		pmap[p.Address()] = p
 	}
-	g := state.NewGroup(addresses, k)
+	g, err := state.NewGroup(addresses, k, mode, height, nonce)
+	if err != nil {
+		return GroupSimulator{}, err
+	}
 
 	// get all members' contribution to the group secret
-	ExchangeSeckeyShares(g, members)
-
-	// build group pubkey
-	pubs := make([]bls.Pubkey, m)
-	for i, p := range members {
-		pubs[i] = bls.PubkeyFromSeckey(p.GetSeckeyForGroup(g))
+	vvec, err := ExchangeSeckeyShares(g, members)
+	if err != nil {
+		return GroupSimulator{}, err
 	}
-	pub := bls.AggregatePubkeys(pubs)
 
-	// set group pubkey in Group struct
-	g.SetPubkey(pub, k)
+	// set the combined verification vector and with it the group pubkey in Group struct
+	if err := g.SetVerificationVector(vvec); err != nil {
+		return GroupSimulator{}, err
+	}
+	pub := g.Pubkey()
+	rec.record("grppub", g.Address().Hex(), pub.String())
 
 	// tell each process to aggregate their shares
 	// processes need their aggregated shares for signing later
@@ -78,9 +107,15 @@ func NewGroupSimulator(members []*ProcessSimulator, k uint16) GroupSimulator {
 		}
 
 		// recover the combined group secret from combined shares
-		// choose k random shares, combine and compare
-		sec = bls.RecoverSeckeyByMap(aggShares, int(k))
-		pubDup := bls.PubkeyFromSeckey(sec)
+		// choose the k shares with the smallest IDs, combine and compare
+		sec, err = recoverSeckey(g, aggShares)
+		if err != nil {
+			return GroupSimulator{}, err
+		}
+		pubDup, err := bls.PubkeyFromSeckey(sec)
+		if err != nil {
+			return GroupSimulator{}, err
+		}
 
 		// optional double-check: aggregate all contributions into the group secret and compare
 		secs := make([]bls.Seckey, m)
@@ -88,43 +123,274 @@ func NewGroupSimulator(members []*ProcessSimulator, k uint16) GroupSimulator {
 			secs[i] = p.GetSeckeyForGroup(g)
 		}
 		secDup := bls.AggregateSeckeys(secs)
-		if sec.String() != secDup.String() {
-			fmt.Println("Error: recovered aggregated seckey does not match.")
+		if !sec.Equal(secDup) {
+			logger.Error("recovered aggregated seckey does not match", "grp", g.Address().Hex())
+			return GroupSimulator{}, ErrSeckeyMismatch
 		}
 
 		if pub.String() != pubDup.String() {
-			fmt.Println("Error: recovered aggregated pubkey does not match.")
+			logger.Error("recovered aggregated pubkey does not match", "grp", g.Address().Hex())
+			return GroupSimulator{}, ErrPubkeyMismatch
 		}
 	}
 
-	return GroupSimulator{sec, g, members, pmap}
+	signers, err := bls.SelectAddrs(addresses, int(k))
+	if err != nil {
+		return GroupSimulator{}, err
+	}
+	basis, err := g.LagrangeBasis(signers)
+	if err != nil {
+		return GroupSimulator{}, err
+	}
+
+	return GroupSimulator{sec, g, members, pmap, signers, basis, 0, rec}, nil
+}
+
+// Refresh -- make the group members re-randomize their shares, the group pubkey stays the same
+// Every member deals a sharing of zero, which all members add to their shares.
+// Shares leaked before the refresh cannot be combined with shares leaked after it.
+func (g *GroupSimulator) Refresh() error {
+	epoch := g.epoch + 1
+	vvecs := make([]bls.VerificationVector, len(g.proclist))
+	for i, p := range g.proclist {
+		shares, vvec, err := p.GetRefreshSharesForGroup(g.reginfo, epoch)
+		if err != nil {
+			return err
+		}
+		vvecs[i] = vvec
+		for _, q := range g.proclist {
+			if err := q.SetRefreshShare(g.reginfo, p.Address(), shares[q.Address()], vvec); err != nil {
+				return err
+			}
+		}
+	}
+	for _, q := range g.proclist {
+		q.ApplyRefresh(g.reginfo)
+	}
+	delta, err := bls.CombineVerificationVectors(vvecs)
+	if err != nil {
+		return err
+	}
+	reginfo, err := g.reginfo.Refresh(delta)
+	if err != nil {
+		return err
+	}
+	g.reginfo, g.epoch = reginfo, epoch
+
+	// optional double-check: the new shares still recover the group secret and match the new vvec
+	if DoubleCheck {
+		shares := bls.SeckeyMap{}
+		for _, q := range g.proclist {
+			shares[q.Address()] = q.GetAggregatedGroupShare(g.reginfo)
+			id, err := g.reginfo.MemberID(q.Address())
+			if err != nil {
+				return err
+			}
+			if err := bls.VerifyShare(g.reginfo.VerificationVector(), id, shares[q.Address()]); err != nil {
+				logger.Error("refreshed share does not match group verification vector", "grp", g.Address().Hex(), "proc", q.Address().Hex())
+				return err
+			}
+		}
+		sec, err := recoverSeckey(g.reginfo, shares)
+		if err != nil {
+			return err
+		}
+		if !sec.Equal(g.sec) {
+			logger.Error("refreshed shares do not recover the group secret", "grp", g.Address().Hex())
+			return ErrSeckeyMismatch
+		}
+	}
+	return nil
+}
+
+// Handover -- hand the group key over to a new list of members with threshold k, the group pubkey stays the same
+// The new group is formed at the given height and keeps the nonce of the old one.
+// The k' old members with the smallest IDs (k' the old threshold) reshare their shares to the new members,
+// who combine them with the Lagrange coefficients of the dealers. The old shares are destroyed if the handover succeeds.
+func (g *GroupSimulator) Handover(members []*ProcessSimulator, k uint16, height uint64) error {
+	old := g.reginfo
+	addresses := make([]common.Address, len(members))
+	pmap := make(map[common.Address]*ProcessSimulator)
+	for i, p := range members {
+		addresses[i] = p.Address()
+		pmap[p.Address()] = p
+	}
+	next, err := state.NewGroup(addresses, k, old.IDMode(), height, old.Nonce())
+	if err != nil {
+		return err
+	}
+
+	// resharing by the dealers
+	dealers := g.signers
+	vvecs := make([]bls.VerificationVector, len(dealers))
+	for i, a := range dealers {
+		shares, vvec, err := g.procmap[a].GetHandoverShares(old, next)
+		if err != nil {
+			return err
+		}
+		vvecs[i] = vvec
+		for _, q := range members {
+			if err := q.SetHandoverShare(old, next, a, shares[q.Address()], vvec); err != nil {
+				return err
+			}
+		}
+	}
+	for _, q := range members {
+		if err := q.CombineHandoverShares(old, next, dealers); err != nil {
+			return err
+		}
+	}
+
+	// the new verification vector is the Lagrange-weighted combination of the dealers' vectors
+	ids, err := old.MemberIDs(dealers)
+	if err != nil {
+		return err
+	}
+	vvec, err := bls.RecoverVerificationVector(vvecs, ids)
+	if err != nil {
+		return err
+	}
+	if next, err = old.Handover(next, vvec); err != nil {
+		return err
+	}
+	g.rec.record("grppub", next.Address().Hex(), next.Pubkey().String())
+
+	signers, err := bls.SelectAddrs(addresses, int(k))
+	if err != nil {
+		return err
+	}
+	basis, err := next.LagrangeBasis(signers)
+	if err != nil {
+		return err
+	}
+	// the old shares are only destroyed once the handover succeeded, until then the old group can still sign
+	for _, p := range g.proclist {
+		p.DropGroupShare(old)
+	}
+	g.reginfo, g.proclist, g.procmap, g.signers, g.basis = next, members, pmap, signers, basis
+
+	// optional double-check: the new shares recover the group secret and match the new vvec
+	if DoubleCheck {
+		shares := bls.SeckeyMap{}
+		for _, q := range members {
+			shares[q.Address()] = q.GetAggregatedGroupShare(next)
+			id, err := next.MemberID(q.Address())
+			if err != nil {
+				return err
+			}
+			if err := bls.VerifyShare(vvec, id, shares[q.Address()]); err != nil {
+				logger.Error("handed over share does not match group verification vector", "grp", next.Address().Hex(), "proc", q.Address().Hex())
+				return err
+			}
+		}
+		sec, err := recoverSeckey(next, shares)
+		if err != nil {
+			return err
+		}
+		if !sec.Equal(g.sec) {
+			logger.Error("handed over shares do not recover the group secret", "grp", next.Address().Hex())
+			return ErrSeckeyMismatch
+		}
+	}
+	return nil
+}
+
+// recoverSeckey -- recover from the shares of the k members with the smallest IDs
+func recoverSeckey(g state.Group, shares bls.SeckeyMap) (bls.Seckey, error) {
+	addrs := make([]common.Address, 0, len(shares))
+	for a := range shares {
+		addrs = append(addrs, a)
+	}
+	addrs, err := bls.SelectAddrs(addrs, g.Threshold())
+	if err != nil {
+		return bls.Seckey{}, err
+	}
+	basis, err := g.LagrangeBasis(addrs)
+	if err != nil {
+		return bls.Seckey{}, err
+	}
+	secs := make([]bls.Seckey, len(addrs))
+	for i, a := range addrs {
+		secs[i] = shares[a]
+	}
+	return bls.RecoverSeckeyByBasis(secs, basis)
+}
+
+// recoverSignature -- recover from the signature shares of the k members with the smallest IDs
+func recoverSignature(g state.Group, shares bls.SignatureMap) (bls.Signature, error) {
+	addrs := make([]common.Address, 0, len(shares))
+	for a := range shares {
+		addrs = append(addrs, a)
+	}
+	addrs, err := bls.SelectAddrs(addrs, g.Threshold())
+	if err != nil {
+		return bls.Signature{}, err
+	}
+	basis, err := g.LagrangeBasis(addrs)
+	if err != nil {
+		return bls.Signature{}, err
+	}
+	sigs := make([]bls.Signature, len(addrs))
+	for i, a := range addrs {
+		sigs[i] = shares[a]
+	}
+	return bls.RecoverSignatureByBasis(sigs, basis)
 }
 
 // Sign -- make the group members jointly create a group signature
-func (g GroupSimulator) Sign(msg []byte) bls.Signature {
+func (g GroupSimulator) Sign(msg []byte) (bls.Signature, error) {
+	sig, _, err := g.sign(msg)
+	return sig, err
+}
+
+// sign -- create the group signature and report the time it took
+func (g GroupSimulator) sign(msg []byte) (bls.Signature, *TimingRecord, error) {
 	sigmap := make(map[common.Address]bls.Signature)
 	// get signature share from each process
 	t0 := time.Now()
 	for _, p := range g.proclist {
-		sigmap[p.Address()] = p.SignForGroup(g.reginfo, msg)
+		share, err := p.SignForGroup(g.reginfo, msg)
+		if err != nil {
+			return bls.Signature{}, nil, err
+		}
+		sigmap[p.Address()] = share
+		g.rec.record("sigshare", g.Address().Hex(), p.Address().Hex(), share.String())
 	}
 	delta1 := time.Since(t0)
 	t1 := time.Now()
-	sig1 := bls.RecoverSignatureByMap(sigmap, g.reginfo.Threshold())
+	sigs := make([]bls.Signature, len(g.signers))
+	for i, a := range g.signers {
+		sigs[i] = sigmap[a]
+	}
+	sig1, err := bls.RecoverSignatureByBasis(sigs, g.basis)
+	if err != nil {
+		return bls.Signature{}, nil, err
+	}
 	delta2 := time.Since(t1)
-	if Timing {
+	if Timing && !Structured() {
 		fmt.Printf("Time for group signatures with %d shares: %v (%vus / share) + %v (recovery).\n", len(g.proclist), delta1, (delta1.Nanoseconds()/1000)/int64(len(g.proclist)), delta2)
 	}
 
 	// optional verification
 	if DoubleCheck {
-		sig2 := bls.Sign(g.sec, msg)
+		// the shares used for recovery can be checked from the group's chain state alone
+		for i, a := range g.signers {
+			if !g.reginfo.VerifySigShare(a, msg, sigs[i]) {
+				logger.Error("signature share does not match verification vector", "grp", g.Address().Hex(), "proc", a.Hex())
+				return bls.Signature{}, nil, ErrInvalidSignature
+			}
+		}
+		sig2, err := bls.Sign(g.sec, msg)
+		if err != nil {
+			return bls.Signature{}, nil, err
+		}
 		if sig1.String() != sig2.String() {
-			fmt.Println("Error in Group sign: Recovered signature does not match.")
+			logger.Error("recovered group signature does not match", "grp", g.Address().Hex())
+			return bls.Signature{}, nil, ErrSignatureMismatch
 		}
 	}
 
-	return sig1
+	return sig1, newTimingRecord(len(g.proclist), delta1, delta2), nil
 }
 
 // Address -- return the address under which the simulated group is registered
@@ -135,11 +401,15 @@ func (g GroupSimulator) Address() common.Address {
 // Log -- print the current state of the simulated group
 func (g GroupSimulator) Log() {
 	fmt.Printf("Group simulator: % x\n", g.reginfo.Address())
-	fmt.Printf("  Seckey: % x\n", g.sec.Bytes())
 	g.reginfo.Log()
 }
 
+// Record -- return the structured output record of the simulated group
+func (g *GroupSimulator) Record() GroupRecord {
+	return GroupRecord{"group", g.reginfo.Record()}
+}
+
 // String -- return a very short summary of the state of the simulated group
 func (g *GroupSimulator) String() string {
-	return fmt.Sprintf("GrpP: (sec)%s %s", g.sec.String()[:4], g.reginfo.String())
+	return fmt.Sprintf("GrpP: %s", g.reginfo.String())
 }
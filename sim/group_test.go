@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"dfinity/beacon/bls"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestHandover(t *testing.T) {
+	sim := newTestSimulator(t, "handover", 3, Config{})
+	if err := sim.Advance(3, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// replace the first member and add two outsiders, raising the threshold
+	g := *sim.group[0]
+	pub := g.reginfo.Pubkey()
+	old := g.reginfo.Members()
+	inGroup := map[common.Address]bool{}
+	for _, a := range old {
+		inGroup[a] = true
+	}
+	addrs := append([]common.Address{}, old[1:]...)
+	for _, p := range sim.proc {
+		if !inGroup[p.Address()] && len(addrs) < 4 {
+			addrs = append(addrs, p.Address())
+		}
+	}
+	if err := sim.Handover(g.Address(), addrs, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	next, ok := sim.grpmap[sim.group[0].Address()]
+	if !ok || next.reginfo.Pubkey().String() != pub.String() || next.reginfo.Threshold() != 3 || next.reginfo.Size() != 4 {
+		t.Fatal("Handover changed the group pubkey or did not apply the new members")
+	}
+	// the handover is included in the next block, the tip is not changed
+	tip := sim.Tip()
+	if _, ok := tip.Group(g.Address()); !ok {
+		t.Error("Handover modified the tip")
+	}
+	if err := sim.Advance(1, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sim.Tip().Group(g.Address()); ok || sim.Tip().GroupPubkey(next.Address()).String() != pub.String() {
+		t.Error("Next block does not register the handed over group")
+	}
+	if _, ok := tip.Group(next.Address()); ok {
+		t.Error("Handover rewrote history")
+	}
+	hist := sim.History()
+	if h, ok := hist.ActiveUntil(g.Address()); !ok || h != sim.Length() {
+		t.Error("History does not record the removal of the old group", h, ok)
+	}
+	if h, ok := hist.ActiveSince(next.Address()); !ok || h != sim.Length() {
+		t.Error("History does not record the activation of the new group", h, ok)
+	}
+	if groups := hist.GroupsOf(addrs[len(addrs)-1], sim.Length()); len(groups) == 0 || groups[len(groups)-1] != next.Address() {
+		t.Error("History does not index the new members", groups)
+	}
+	sig, err := next.Sign([]byte("hi"))
+	if err != nil || !bls.VerifySig(pub, []byte("hi"), sig) {
+		t.Error("New members do not sign under the group pubkey", err)
+	}
+	for i := range sim.proc {
+		p := &sim.proc[i]
+		if _, ok := p.sharesCombined[g.Address()]; ok && g.Address() != next.Address() {
+			t.Error("Process still holds a share of the old group", p.Address().Hex())
+		}
+	}
+
+	// the handed over group keeps producing verifiable beacons (double-checked against the group secret)
+	if err := sim.Advance(9, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.VerifyChain(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the old group is gone
+	if err := sim.Handover(g.Address(), addrs, 3); err != ErrUnknownGroup {
+		t.Error("Expected ErrUnknownGroup, got", err)
+	}
+}
@@ -0,0 +1,44 @@
+package sim
+
+import (
+	dfn "dfinity/beacon/common"
+	"errors"
+)
+
+// Errors
+
+// ErrInvalidSignature -- a group signature does not verify against the group pubkey
+var ErrInvalidSignature = errors.New("sim: group signature not valid")
+
+// ErrSeckeyMismatch -- a recovered secret key differs from the expected one (double-check)
+var ErrSeckeyMismatch = errors.New("sim: recovered seckey does not match")
+
+// ErrPubkeyMismatch -- a recovered pubkey differs from the expected one (double-check)
+var ErrPubkeyMismatch = errors.New("sim: recovered pubkey does not match")
+
+// ErrSignatureMismatch -- a recovered signature differs from the expected one (double-check)
+var ErrSignatureMismatch = errors.New("sim: recovered signature does not match")
+
+// ErrUnknownGroup -- no simulator exists for the selected group
+var ErrUnknownGroup = errors.New("sim: unknown group")
+
+// ErrUnknownProcess -- there is no simulator for a process address
+var ErrUnknownProcess = errors.New("sim: unknown process")
+
+// ErrInvalidProof -- a Merkle proof does not verify against the state root (double-check)
+var ErrInvalidProof = errors.New("sim: state proof not valid")
+
+// ErrSnapshotKeys -- the key material for a snapshot cannot be derived from the seed
+var ErrSnapshotKeys = errors.New("sim: snapshot keys not derivable from seed")
+
+// ErrNoDomain -- an application message was submitted without a domain tag
+var ErrNoDomain = errors.New("sim: application message without domain")
+
+// Logging
+
+var logger dfn.Logger = dfn.NopLogger{}
+
+// SetLogger -- set the logger used by the package (default discards everything)
+func SetLogger(l dfn.Logger) {
+	logger = l
+}
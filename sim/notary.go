@@ -0,0 +1,248 @@
+package sim
+
+import (
+	"dfinity/beacon/bls"
+	"dfinity/beacon/state"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math"
+)
+
+// Notarization
+//
+// With Config.Notarize set, the group selected by the tip does not only produce the next random output, it also
+// notarizes (threshold-signs) one of the ranked candidate blocks. Candidates extend the head of the tree of
+// notarized blocks, which is chosen by weight: every block weighs 2^-rank, a chain weighs the sum of its blocks.
+// The random outputs and with them the states do not depend on the notarized blocks, only the proposers differ.
+//
+// A notarization can be delayed (Config.NotaryDelay): the committee notarizes the second-ranked candidate in time and
+// the best one only in the next round, when the next block already extends the other one. Both are notarized,
+// the chain forks. Notarizations arrive at most one round late, so once a height with a single notarized block
+// is followed by two more notarized heights, that block and its ancestors are final and competing blocks are pruned.
+
+// NotarizedBlock -- a candidate block signed by the group selected in the previous round
+type NotarizedBlock struct {
+	Height   int
+	Parent   common.Hash
+	Proposer common.Address
+	Rank     int
+	// root of the state at Height
+	Root         common.Hash
+	Notarization bls.Signature
+}
+
+// Hash -- the block hash, the message signed by the notarization
+// It covers parent, height, proposer, rank and state root.
+func (b NotarizedBlock) Hash() common.Hash {
+	var h [16]byte
+	binary.BigEndian.PutUint64(h[:8], uint64(b.Height))
+	binary.BigEndian.PutUint64(h[8:], uint64(b.Rank))
+	return crypto.Keccak256Hash(b.Parent[:], h[:], b.Proposer[:], b.Root[:])
+}
+
+// Weight -- the weight of the block in the fork choice, 2^-rank
+func (b NotarizedBlock) Weight() float64 {
+	return math.Ldexp(1, -b.Rank)
+}
+
+// Forks -- tree of notarized blocks with fork choice and finality
+type Forks struct {
+	blocks   map[common.Hash]NotarizedBlock
+	byHeight map[int][]common.Hash
+	// weight of the chain up to each block
+	weight map[common.Hash]float64
+	head   common.Hash
+	final  common.Hash
+	// greatest height with a notarized block
+	top int
+}
+
+// NewForks -- a tree with the given root block, e.g. the genesis block, which is final
+func NewForks(root NotarizedBlock) *Forks {
+	h := root.Hash()
+	return &Forks{
+		blocks:   map[common.Hash]NotarizedBlock{h: root},
+		byHeight: map[int][]common.Hash{root.Height: {h}},
+		weight:   map[common.Hash]float64{h: 0},
+		head:     h,
+		final:    h,
+		top:      root.Height,
+	}
+}
+
+// Add -- add a notarized block, update the head and finality
+// Returns whether the block was added, blocks that conflict with finality or are known already are not.
+func (f *Forks) Add(b NotarizedBlock) bool {
+	h := b.Hash()
+	parent, ok := f.blocks[b.Parent]
+	if _, known := f.blocks[h]; known || !ok || b.Height != parent.Height+1 || b.Height <= f.blocks[f.final].Height {
+		return false
+	}
+	f.blocks[h] = b
+	f.byHeight[b.Height] = append(f.byHeight[b.Height], h)
+	f.weight[h] = f.weight[b.Parent] + b.Weight()
+	// ties go to the block seen first
+	if f.weight[h] > f.weight[f.head] {
+		f.head = h
+	}
+	if b.Height > f.top {
+		f.top = b.Height
+	}
+	f.finalize()
+	return true
+}
+
+// finalize -- move finality to the greatest height with a single notarized block that cannot get a competitor anymore
+func (f *Forks) finalize() {
+	for height := f.top - 2; height > f.blocks[f.final].Height; height-- {
+		if len(f.byHeight[height]) == 1 {
+			f.final = f.byHeight[height][0]
+			f.prune()
+			return
+		}
+	}
+}
+
+// prune -- remove all blocks up to the final height that are not ancestors of the final block
+func (f *Forks) prune() {
+	keep := make(map[common.Hash]bool)
+	for h := f.final; ; {
+		keep[h] = true
+		b := f.blocks[h]
+		if _, ok := f.blocks[b.Parent]; !ok {
+			break
+		}
+		h = b.Parent
+	}
+	fh := f.blocks[f.final].Height
+	for height, hashes := range f.byHeight {
+		if height > fh {
+			continue
+		}
+		var kept []common.Hash
+		for _, h := range hashes {
+			if keep[h] {
+				kept = append(kept, h)
+			} else {
+				delete(f.blocks, h)
+				delete(f.weight, h)
+			}
+		}
+		f.byHeight[height] = kept
+	}
+	// descendants of pruned blocks cannot become final anymore
+	for height := fh + 1; height <= f.top; height++ {
+		var kept []common.Hash
+		for _, h := range f.byHeight[height] {
+			if _, ok := f.blocks[f.blocks[h].Parent]; ok {
+				kept = append(kept, h)
+			} else {
+				delete(f.blocks, h)
+				delete(f.weight, h)
+			}
+		}
+		f.byHeight[height] = kept
+	}
+}
+
+// Head -- the block chosen by the fork-choice rule
+func (f *Forks) Head() NotarizedBlock {
+	return f.blocks[f.head]
+}
+
+// Final -- the last final block
+func (f *Forks) Final() NotarizedBlock {
+	return f.blocks[f.final]
+}
+
+// AtHeight -- the notarized blocks at the given height, in the order in which they were added
+func (f *Forks) AtHeight(height int) []NotarizedBlock {
+	blocks := make([]NotarizedBlock, len(f.byHeight[height]))
+	for i, h := range f.byHeight[height] {
+		blocks[i] = f.blocks[h]
+	}
+	return blocks
+}
+
+// Chain -- the blocks from the root (or the oldest block kept) to the head
+func (f *Forks) Chain() []NotarizedBlock {
+	var chain []NotarizedBlock
+	for b, ok := f.Head(), true; ok; b, ok = f.blocks[b.Parent] {
+		chain = append([]NotarizedBlock{b}, chain...)
+	}
+	return chain
+}
+
+// NotarizationRecord -- a notarized block
+type NotarizationRecord struct {
+	Type     string `json:"type"`
+	Height   int    `json:"height"`
+	Rank     int    `json:"rank"`
+	Proposer string `json:"proposer"`
+	Hash     string `json:"hash"`
+	Parent   string `json:"parent"`
+	Delayed  bool   `json:"delayed,omitempty"`
+	Head     string `json:"head"`
+	Final    int    `json:"final"`
+}
+
+// notaryDelayed -- whether the notarization of the best candidate after tip is delayed
+func (sim *BlockchainSimulator) notaryDelayed(tip state.State, candidates int) bool {
+	delay := sim.cfg.NotaryDelay
+	return delay > 0 && candidates > 1 && uint(tip.Rand().Ders("notary").Modulo(100)) < delay
+}
+
+// notarize -- let the committee g, registered under a and selected by tip, notarize candidates for the state s of the next block
+// The candidate of rank inTime is added to the tree at once. If it is not the best one, the best one is
+// notarized too, but added only at the end of the next round. Notarizations delayed from the previous round
+// are added after the one in time.
+func (sim *BlockchainSimulator) notarize(g *GroupSimulator, a common.Address, tip state.State, s state.State, candidates []common.Address, inTime int) error {
+	height := sim.Length()
+	parent := sim.forks.Head().Hash()
+	late := sim.late
+	sim.late = nil
+	ranks := []int{inTime}
+	if inTime != 0 {
+		ranks = append(ranks, 0)
+	}
+	for _, rank := range ranks {
+		b := NotarizedBlock{Height: height, Parent: parent, Proposer: candidates[rank], Rank: rank, Root: s.Root()}
+		hash := b.Hash()
+		sig, _, err := g.sign(hash[:])
+		if err != nil {
+			return err
+		}
+		if DoubleCheck && !bls.VerifySig(tip.GroupPubkey(a), hash[:], sig) {
+			logger.Error("notarization not valid", "height", height, "grp", a.Hex())
+			return ErrInvalidSignature
+		}
+		b.Notarization = sig
+		if rank == inTime {
+			sim.addNotarized(b, false)
+		} else {
+			sim.late = append(sim.late, b)
+		}
+	}
+	for _, b := range late {
+		sim.addNotarized(b, true)
+	}
+	return nil
+}
+
+// addNotarized -- add a notarized block to the tree and emit it
+func (sim *BlockchainSimulator) addNotarized(b NotarizedBlock, delayed bool) {
+	if !sim.forks.Add(b) {
+		logger.Debug("notarized block not added", "height", b.Height, "rank", b.Rank)
+		return
+	}
+	hash, head := b.Hash(), sim.forks.Head().Hash()
+	sim.cfg.Recorder.record("notarization", b.Height, b.Rank, hex.EncodeToString(hash[:]), b.Notarization.String())
+	Emit(NotarizationRecord{"notarization", b.Height, b.Rank, b.Proposer.Hex(), hash.Hex(), b.Parent.Hex(), delayed, head.Hex(), sim.forks.Final().Height})
+}
+
+// Forks -- the tree of notarized blocks, nil unless Notarize was set when the simulator was created
+func (sim *BlockchainSimulator) Forks() *Forks {
+	return sim.forks
+}
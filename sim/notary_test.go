@@ -0,0 +1,78 @@
+package sim
+
+import (
+	"dfinity/beacon/bls"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestForks(t *testing.T) {
+	genesis := NotarizedBlock{Height: 1}
+	f := NewForks(genesis)
+	block := func(parent NotarizedBlock, rank int) NotarizedBlock {
+		return NotarizedBlock{Height: parent.Height + 1, Parent: parent.Hash(), Proposer: common.BytesToAddress([]byte{byte(rank)}), Rank: rank}
+	}
+	// height 2 forks, the rank 1 block is extended first
+	b2, a2 := block(genesis, 1), block(genesis, 0)
+	b3 := block(b2, 0)
+	for _, b := range []NotarizedBlock{b2, b3, a2} {
+		if !f.Add(b) {
+			t.Fatal("Add failed at height", b.Height)
+		}
+	}
+	// 0.5+1 outweighs 1
+	if f.Head().Hash() != b3.Hash() || len(f.AtHeight(2)) != 2 || f.Final().Height != 1 {
+		t.Fatal("Wrong head or final block after fork")
+	}
+	a3 := block(a2, 1)
+	f.Add(a3)
+	if f.Head().Hash() != b3.Hash() {
+		t.Error("Tie does not go to the block seen first")
+	}
+	// height 3 has two blocks, height 4 only one: once height 6 is notarized, height 4 is final
+	b4 := block(b3, 1)
+	b5 := block(b4, 0)
+	b6 := block(b5, 0)
+	for _, b := range []NotarizedBlock{b4, b5, b6} {
+		f.Add(b)
+	}
+	if f.Final().Hash() != b4.Hash() {
+		t.Fatal("Wrong final block", f.Final().Height)
+	}
+	if len(f.AtHeight(2)) != 1 || len(f.AtHeight(3)) != 1 || f.AtHeight(2)[0].Hash() != b2.Hash() {
+		t.Error("Competing blocks below the final one are not pruned")
+	}
+	if f.Add(block(b3, 0)) {
+		t.Error("Block at a final height added")
+	}
+	chain := f.Chain()
+	if len(chain) != 6 || chain[0].Height != 1 || chain[5].Hash() != b6.Hash() {
+		t.Error("Wrong chain to the head", len(chain))
+	}
+}
+
+func TestNotarization(t *testing.T) {
+	sim := newTestSimulator(t, "notary", 4, Config{Proposers: 3, Notarize: true, NotaryDelay: 50})
+	if err := sim.Advance(20, false); err != nil {
+		t.Fatal(err)
+	}
+	f := sim.Forks()
+	if f.Head().Height != sim.Length() || f.Final().Height < sim.Length()-4 {
+		t.Fatal("Head or final block lag behind", f.Head().Height, f.Final().Height)
+	}
+	// every block on the chain is notarized by the group selected in the previous round
+	for _, b := range f.Chain()[1:] {
+		prev := sim.Block(b.Height - 1)
+		hash := b.Hash()
+		if b.Root != sim.Block(b.Height).Root() || !bls.VerifySig(prev.SelectedGroupPubkey(), hash[:], b.Notarization) {
+			t.Error("Invalid notarization at height", b.Height)
+		}
+	}
+
+	// delayed notarizations fork the chain and are recorded
+	p := goldenParams
+	p.Proposers, p.Notarize, p.Delay = 3, true, 50
+	if n := len(linesWith(recordReplayed(t, p), "notarization ")); n <= int(p.Length) {
+		t.Error("No delayed notarizations:", n)
+	}
+}
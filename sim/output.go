@@ -0,0 +1,115 @@
+package sim
+
+import (
+	"dfinity/beacon/state"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Output formats
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatNone   = "none"
+)
+
+// Format -- output format of the simulation, one of FormatText, FormatJSON, FormatNDJSON, FormatNone
+var Format = FormatText
+
+// Out -- destination of the structured output
+var Out io.Writer = os.Stdout
+
+// records collected in FormatJSON until Flush is called
+var records []interface{}
+
+// RunRecord -- parameters of a simulation run
+type RunRecord struct {
+	Type      string `json:"type"`
+	Curve     string `json:"curve"`
+	Seed      string `json:"seed"`
+	GroupSize uint16 `json:"n"`
+	Threshold uint16 `json:"k"`
+}
+
+// ProcessRecord -- a simulated process as registered on the blockchain
+type ProcessRecord struct {
+	Type string `json:"type"`
+	state.NodeRecord
+}
+
+// GroupRecord -- a simulated group as registered on the blockchain
+type GroupRecord struct {
+	Type string `json:"type"`
+	state.GroupRecord
+}
+
+// CandidateRecord -- a candidate for the next block, by a proposer with the given rank and priority
+type CandidateRecord struct {
+	Type     string `json:"type"`
+	Height   int    `json:"height"`
+	Rank     int    `json:"rank"`
+	Proposer string `json:"proposer"`
+	Priority string `json:"prio"`
+}
+
+// TimingRecord -- time spent by a group to produce a group signature
+type TimingRecord struct {
+	Shares    int   `json:"shares"`
+	SignNs    int64 `json:"sign_ns"`
+	RecoverNs int64 `json:"recover_ns"`
+}
+
+// BlockRecord -- a block of the simulated chain
+type BlockRecord struct {
+	Type   string `json:"type"`
+	Height int    `json:"height"`
+	Signer string `json:"signer,omitempty"`
+	state.StateRecord
+	Timing *TimingRecord `json:"timing,omitempty"`
+}
+
+// Structured -- true if the output format is not text (text output is suppressed)
+func Structured() bool {
+	return Format != FormatText
+}
+
+// Emit -- write a record in the structured output format, no-op in FormatText
+func Emit(rec interface{}) {
+	switch Format {
+	case FormatJSON:
+		records = append(records, rec)
+	case FormatNDJSON:
+		b, err := json.Marshal(rec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: cannot encode record:", err)
+			return
+		}
+		fmt.Fprintf(Out, "%s\n", b)
+	}
+}
+
+// Flush -- write out all records collected in FormatJSON as one array
+func Flush() {
+	if Format != FormatJSON {
+		return
+	}
+	if records == nil {
+		records = []interface{}{}
+	}
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: cannot encode records:", err)
+		return
+	}
+	fmt.Fprintf(Out, "%s\n", b)
+	records = nil
+}
+
+// newTimingRecord --
+func newTimingRecord(shares int, sign time.Duration, recover time.Duration) *TimingRecord {
+	return &TimingRecord{shares, sign.Nanoseconds(), recover.Nanoseconds()}
+}
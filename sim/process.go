@@ -2,6 +2,7 @@ package sim
 
 import (
 	"dfinity/beacon/bls"
+	"dfinity/beacon/elgamal"
 	"dfinity/beacon/state"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
@@ -17,15 +18,26 @@ type ProcessSimulator struct {
 	// rseed is the seed used for the internal randomness of the process, it did not seed the secret key
 	sharesSource   map[common.Address]bls.SeckeyMap
 	sharesCombined bls.SeckeyMap
+	// incoming shares of zero for the next refresh, by group and source
+	sharesRefresh map[common.Address]bls.SeckeyMap
+	// incoming reshared shares of a group handed over to this process, by new group and source
+	sharesHandover map[common.Address]bls.SeckeyMap
+	// transcript of the run, nil if it is not recorded
+	rec *Transcript
 }
 
 // NewProcessSimulator -- create a new simulator given process data such as seed and private key
-func NewProcessSimulator(sec bls.Seckey, seed bls.Rand) (p ProcessSimulator) {
+func NewProcessSimulator(sec bls.Seckey, seed bls.Rand) (p ProcessSimulator, err error) {
 	p.sec = sec
-	p.reginfo = state.NodeFromSeckey(sec)
+	p.reginfo, err = state.NodeFromSeckey(sec)
+	if err != nil {
+		return
+	}
 	p.rseed = seed
 	p.sharesSource = make(map[common.Address]bls.SeckeyMap)
 	p.sharesCombined = bls.SeckeyMap{}
+	p.sharesRefresh = make(map[common.Address]bls.SeckeyMap)
+	p.sharesHandover = make(map[common.Address]bls.SeckeyMap)
 	return
 }
 
@@ -34,8 +46,11 @@ func NewProcessSimulator(sec bls.Seckey, seed bls.Rand) (p ProcessSimulator) {
 // This makes the process simulator DETERMINISTIC by setting rseed to the process' own address.
 // Since the address is public the process' behaviour becomes predictable from the outside.
 // This will benefit testing.
-func NewProcessSimulatorDet(sec bls.Seckey) ProcessSimulator {
-	node := state.NodeFromSeckey(sec)
+func NewProcessSimulatorDet(sec bls.Seckey) (ProcessSimulator, error) {
+	node, err := state.NodeFromSeckey(sec)
+	if err != nil {
+		return ProcessSimulator{}, err
+	}
 	// assign temporary variable to make the value addressable
 	tmp := node.Address()
 	return NewProcessSimulator(sec, bls.RandFromBytes(tmp[:]))
@@ -47,12 +62,18 @@ func (p *ProcessSimulator) Address() common.Address {
 }
 
 // SetGroupShare -- set the incoming shares from other group members
-func (p *ProcessSimulator) SetGroupShare(addr common.Address, source common.Address, share bls.Seckey, vvec []bls.Pubkey) {
+func (p *ProcessSimulator) SetGroupShare(g state.Group, source common.Address, share bls.Seckey, vvec bls.VerificationVector) error {
+	addr := g.Address()
 	//	fmt.Printf("Setting source share: (proc)%.4x (grp)%.2x (src)%.4x (sec)%.4s\n", p.Address(), addr, source, share.String())
 	// verify share
 	if Vvec {
-		if bls.SharePubkey(vvec, p.reginfo.ID()).String() != bls.PubkeyFromSeckey(share).String() {
-			fmt.Println("Error: Received secret share does not match committed verification vector")
+		id, err := g.MemberID(p.Address())
+		if err != nil {
+			return err
+		}
+		if err := bls.VerifyShare(vvec, id, share); err != nil {
+			logger.Error("received secret share does not match committed verification vector", "proc", p.Address().Hex(), "grp", addr.Hex(), "src", source.Hex(), "err", err)
+			return err
 		}
 	}
 
@@ -63,7 +84,9 @@ func (p *ProcessSimulator) SetGroupShare(addr common.Address, source common.Addr
 	}
 	// store source share
 	p.sharesSource[addr][source] = share
-	return
+	// reveal: the transcript is an explicit export of all key material of a simulation run
+	p.rec.record("share", addr.Hex(), source.Hex(), p.Address().Hex(), share.RevealHex())
+	return nil
 }
 
 // AggregateGroupShares -- aggregate (sum up) all the shares that came in from members of the given group
@@ -76,6 +99,11 @@ func (p *ProcessSimulator) AggregateGroupShares(g state.Group) {
 		i++
 	}
 	p.sharesCombined[addr] = bls.AggregateSeckeys(vlist)
+	// the individual shares are not needed anymore
+	for _, sec := range vlist {
+		sec.Destroy()
+	}
+	delete(p.sharesSource, addr)
 	return
 }
 
@@ -95,46 +123,248 @@ func (p *ProcessSimulator) GetSeckeyForGroup(g state.Group) (sec bls.Seckey) {
 
 // GetSeckeySharesForGroup -- take own secret for the group setup (function of internal seed and group address) and split it up in shares for all group members
 // from the process seed (rseed) and derive a per-group seed based on the group's address
-func (p *ProcessSimulator) GetSeckeySharesForGroup(g state.Group) (bls.SeckeyMap, []bls.Pubkey) {
+func (p *ProcessSimulator) GetSeckeySharesForGroup(g state.Group) (bls.SeckeyMap, bls.VerificationVector, error) {
 	addr := g.Address()
 	gseed := p.rseed.DerivedRand(addr[:])
 	// from the per-group seed derive a vector of k seckeys as the master seckey where k is the threshold
 	// the master seckey defines a polynomial of degree k-1
 	k := g.Threshold()
 	msec := make([]bls.Seckey, k)
-	vvec := make([]bls.Pubkey, k)
 	for i := 0; i < k; i++ {
 		msec[i] = bls.SeckeyFromRand(gseed.Deri(i))
-		vvec[i] = bls.PubkeyFromSeckey(msec[i])
+	}
+	vvec, err := bls.VerificationVectorFromSeckeys(msec)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, pub := range vvec {
+		p.rec.record("vvec", addr.Hex(), p.Address().Hex(), i, pub.String())
+	}
+	shares := bls.SeckeyMap{}
+	for _, m := range g.Members() {
+		id, err := g.MemberID(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		if shares[m], err = bls.ShareSeckey(msec, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, sec := range msec {
+		sec.Destroy()
+	}
+	return shares, vvec, nil
+}
+
+// GetRefreshSharesForGroup -- deal a sharing of zero to all group members for the given refresh epoch
+// Like the setup shares, the sharing is a function of the internal seed, the group address and the epoch.
+func (p *ProcessSimulator) GetRefreshSharesForGroup(g state.Group, epoch int) (bls.SeckeyMap, bls.VerificationVector, error) {
+	addr := g.Address()
+	rseed := p.rseed.DerivedRand(addr[:]).Ders("refresh").Deri(epoch)
+	// the constant coefficient is zero, the others are random
+	k := g.Threshold()
+	msec := make([]bls.Seckey, k)
+	msec[0] = bls.SeckeyFromInt(0)
+	for i := 1; i < k; i++ {
+		msec[i] = bls.SeckeyFromRand(rseed.Deri(i))
+	}
+	vvec, err := bls.VerificationVectorFromSeckeys(msec)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, pub := range vvec {
+		p.rec.record("rvvec", addr.Hex(), p.Address().Hex(), i, pub.String())
 	}
 	shares := bls.SeckeyMap{}
 	for _, m := range g.Members() {
-		shares[m] = bls.ShareSeckeyByAddr(msec, m)
+		id, err := g.MemberID(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		if shares[m], err = bls.ShareSeckey(msec, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, sec := range msec {
+		sec.Destroy()
+	}
+	return shares, vvec, nil
+}
+
+// SetRefreshShare -- set an incoming share of zero from another group member
+func (p *ProcessSimulator) SetRefreshShare(g state.Group, source common.Address, share bls.Seckey, vvec bls.VerificationVector) error {
+	addr := g.Address()
+	// a sharing of anything but zero would change the group pubkey
+	if !vvec.IsZeroSharing() {
+		logger.Error("received refresh is not a sharing of zero", "proc", p.Address().Hex(), "grp", addr.Hex(), "src", source.Hex())
+		return state.ErrInvalidRefresh
+	}
+	if Vvec {
+		id, err := g.MemberID(p.Address())
+		if err != nil {
+			return err
+		}
+		if err := bls.VerifyShare(vvec, id, share); err != nil {
+			logger.Error("received refresh share does not match committed verification vector", "proc", p.Address().Hex(), "grp", addr.Hex(), "src", source.Hex(), "err", err)
+			return err
+		}
+	}
+	if _, exists := p.sharesRefresh[addr]; !exists {
+		p.sharesRefresh[addr] = bls.SeckeyMap{}
+	}
+	p.sharesRefresh[addr][source] = share
+	// reveal: the transcript is an explicit export of all key material of a simulation run
+	p.rec.record("rshare", addr.Hex(), source.Hex(), p.Address().Hex(), share.RevealHex())
+	return nil
+}
+
+// ApplyRefresh -- add all received shares of zero to the own group share
+// The old share and the shares of zero are destroyed.
+func (p *ProcessSimulator) ApplyRefresh(g state.Group) {
+	addr := g.Address()
+	vlist := []bls.Seckey{p.sharesCombined[addr]}
+	for _, sec := range p.sharesRefresh[addr] {
+		vlist = append(vlist, sec)
+	}
+	p.sharesCombined[addr] = bls.AggregateSeckeys(vlist)
+	for _, sec := range vlist {
+		sec.Destroy()
+	}
+	delete(p.sharesRefresh, addr)
+}
+
+// GetHandoverShares -- reshare the own share of group old to the members of group next
+// The sharing polynomial has the own share as constant coefficient and next's threshold as degree+1,
+// the other coefficients are a function of the internal seed and both group addresses.
+func (p *ProcessSimulator) GetHandoverShares(old state.Group, next state.Group) (bls.SeckeyMap, bls.VerificationVector, error) {
+	oldAddr, nextAddr := old.Address(), next.Address()
+	share, ok := p.sharesCombined[oldAddr]
+	if !ok {
+		return nil, nil, state.ErrNotMember
+	}
+	hseed := p.rseed.DerivedRand(oldAddr[:]).Ders("handover").DerivedRand(nextAddr[:])
+	k := next.Threshold()
+	msec := make([]bls.Seckey, k)
+	msec[0] = share
+	for i := 1; i < k; i++ {
+		msec[i] = bls.SeckeyFromRand(hseed.Deri(i))
+	}
+	vvec, err := bls.VerificationVectorFromSeckeys(msec)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, pub := range vvec {
+		p.rec.record("hvvec", nextAddr.Hex(), p.Address().Hex(), i, pub.String())
+	}
+	shares := bls.SeckeyMap{}
+	for _, m := range next.Members() {
+		id, err := next.MemberID(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		if shares[m], err = bls.ShareSeckey(msec, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	// the own share itself is kept until DropGroupShare
+	for _, sec := range msec[1:] {
+		sec.Destroy()
+	}
+	return shares, vvec, nil
+}
+
+// SetHandoverShare -- set an incoming reshared share from a member of group old
+func (p *ProcessSimulator) SetHandoverShare(old state.Group, next state.Group, source common.Address, share bls.Seckey, vvec bls.VerificationVector) error {
+	nextAddr := next.Address()
+	if Vvec {
+		// the dealer has to reshare its actual share of old, whose pubkey is known from old's verification vector
+		pub, err := old.MemberPubkey(source)
+		if err != nil {
+			return err
+		}
+		if vvec.Pubkey().String() != pub.String() {
+			logger.Error("received handover does not reshare the dealer's share", "proc", p.Address().Hex(), "grp", nextAddr.Hex(), "src", source.Hex())
+			return state.ErrInvalidHandover
+		}
+		id, err := next.MemberID(p.Address())
+		if err != nil {
+			return err
+		}
+		if err := bls.VerifyShare(vvec, id, share); err != nil {
+			logger.Error("received handover share does not match committed verification vector", "proc", p.Address().Hex(), "grp", nextAddr.Hex(), "src", source.Hex(), "err", err)
+			return err
+		}
+	}
+	if _, exists := p.sharesHandover[nextAddr]; !exists {
+		p.sharesHandover[nextAddr] = bls.SeckeyMap{}
+	}
+	p.sharesHandover[nextAddr][source] = share
+	// reveal: the transcript is an explicit export of all key material of a simulation run
+	p.rec.record("hshare", nextAddr.Hex(), source.Hex(), p.Address().Hex(), share.RevealHex())
+	return nil
+}
+
+// CombineHandoverShares -- combine the reshared shares from the dealers (members of old) into the own share of next
+func (p *ProcessSimulator) CombineHandoverShares(old state.Group, next state.Group, dealers []common.Address) error {
+	nextAddr := next.Address()
+	basis, err := old.LagrangeBasis(dealers)
+	if err != nil {
+		return err
+	}
+	secs := make([]bls.Seckey, len(dealers))
+	for i, a := range dealers {
+		sec, ok := p.sharesHandover[nextAddr][a]
+		if !ok {
+			return bls.ErrTooFewShares
+		}
+		secs[i] = sec
+	}
+	p.sharesCombined[nextAddr], err = bls.RecoverSeckeyByBasis(secs, basis)
+	for _, sec := range p.sharesHandover[nextAddr] {
+		sec.Destroy()
+	}
+	delete(p.sharesHandover, nextAddr)
+	return err
+}
+
+// DropGroupShare -- destroy the own share of a group, e.g. after it was handed over
+func (p *ProcessSimulator) DropGroupShare(g state.Group) {
+	addr := g.Address()
+	if sec, ok := p.sharesCombined[addr]; ok {
+		sec.Destroy()
+		delete(p.sharesCombined, addr)
 	}
-	return shares, vvec
 }
 
 // SignForGroup -- return the signature share for the given message and group
-func (p *ProcessSimulator) SignForGroup(g state.Group, msg []byte) bls.Signature {
+func (p *ProcessSimulator) SignForGroup(g state.Group, msg []byte) (bls.Signature, error) {
 	sec := p.sharesCombined[g.Address()]
 	//	fmt.Printf("sign for group: (grp)%.2x (sec)%x\n", g.Address(), sec.String())
 	return bls.Sign(sec, msg)
 }
 
+// DecryptForGroup -- return the decryption share for a ciphertext encrypted to the given group
+func (p *ProcessSimulator) DecryptForGroup(g state.Group, c elgamal.Ciphertext) (elgamal.Share, error) {
+	return elgamal.NewShare(p.Address(), p.sharesCombined[g.Address()], c)
+}
+
 // Sign -- return the own individual signature for the given message
-func (p *ProcessSimulator) Sign(msg []byte) bls.Signature {
+func (p *ProcessSimulator) Sign(msg []byte) (bls.Signature, error) {
 	return bls.Sign(p.sec, msg)
 }
 
 // Log -- print the state of the simulated process
 func (p *ProcessSimulator) Log() {
 	fmt.Printf("Process simulator: % x\n", p.reginfo.Address())
-	fmt.Printf("  Seckey: % x\n", p.sec.Bytes())
-	fmt.Printf("  rseed: % x\n", p.rseed)
 	p.reginfo.Log()
 }
 
+// Record -- return the structured output record of the simulated process
+func (p *ProcessSimulator) Record() ProcessRecord {
+	return ProcessRecord{"process", p.reginfo.Record()}
+}
+
 // String -- return a very short summary of the state of the simulated process
 func (p *ProcessSimulator) String() string {
-	return fmt.Sprintf("Proc: (sec)%s (seed)%x %s", p.sec.String()[:4], p.rseed.String()[:2], p.reginfo.String())
+	return fmt.Sprintf("Proc: %s", p.reginfo.String())
 }
@@ -0,0 +1,263 @@
+package sim
+
+import (
+	"bufio"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"dfinity/beacon/state"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrBadTranscript -- a transcript could not be parsed
+var ErrBadTranscript = errors.New("sim: malformed transcript")
+
+// Transcript -- line-based record of a simulation run
+// The first line holds the parameters of the run, followed by options that differ from their defaults:
+//
+//	params <seed> <n> <k> <N> <m> <l> [curve=<name>] [ids=<mode>] [refresh=<R>] [proposers=<P>] [notarize=<D>] [form=<F>]
+//
+// All further lines hold one value each:
+//
+//	proc <addr> <pub>
+//	vvec <grp> <src> <i> <pub>
+//	share <grp> <src> <dst> <sec>
+//	grppub <grp> <pub>
+//	rvvec <grp> <src> <i> <pub>
+//	rshare <grp> <src> <dst> <sec>
+//	sigshare <grp> <member> <sig>
+//	candidate <height> <rank> <proposer> <prio>
+//	beacon <height> <grp> <sig> <rnd>
+//	notarization <height> <rank> <hash> <sig>
+//	cert <grp> <signer> <sig>
+//
+// The curve is the one the values were computed on, transcripts without it replay on the current curve.
+// The option notarize enables notarization with a delay of D percent, form the formation of a group every F blocks.
+type Transcript struct {
+	lines []string
+}
+
+// TranscriptParams -- the parameters from which a run is replayed
+type TranscriptParams struct {
+	Seed      bls.Rand
+	GroupSize uint16
+	Threshold uint16
+	Processes uint
+	Groups    uint16
+	Length    uint
+	// name of the pairing curve, see blscgo.Curves
+	Curve     string
+	IDMode    state.IDMode
+	Refresh   uint
+	Proposers uint
+	Notarize  bool
+	Delay     uint
+	Form      uint
+}
+
+// Config -- the options of the run, recording to rec
+func (p TranscriptParams) Config(rec *Transcript) Config {
+	return Config{IDMode: p.IDMode, RefreshInterval: p.Refresh, Proposers: p.Proposers, Notarize: p.Notarize, NotaryDelay: p.Delay, FormInterval: p.Form, Recorder: rec}
+}
+
+// MismatchError -- first line in which a replayed transcript differs from the recorded one
+type MismatchError struct {
+	Line int
+	Want string
+	Got  string
+}
+
+// Error --
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("sim: transcript mismatch in line %d:\n  want: %s\n  got:  %s", e.Line, e.Want, e.Got)
+}
+
+// NewTranscript -- create an empty transcript for a run with the given parameters
+func NewTranscript(p TranscriptParams) *Transcript {
+	header := fmt.Sprintf("params %x %d %d %d %d %d", p.Seed.Bytes(), p.GroupSize, p.Threshold, p.Processes, p.Groups, p.Length)
+	if p.Curve != "" {
+		header += " curve=" + p.Curve
+	}
+	if p.IDMode != state.IDByAddress {
+		header += " ids=" + p.IDMode.String()
+	}
+	if p.Refresh != 0 {
+		header += fmt.Sprintf(" refresh=%d", p.Refresh)
+	}
+	if p.Proposers != 0 {
+		header += fmt.Sprintf(" proposers=%d", p.Proposers)
+	}
+	if p.Notarize {
+		header += fmt.Sprintf(" notarize=%d", p.Delay)
+	}
+	if p.Form != 0 {
+		header += fmt.Sprintf(" form=%d", p.Form)
+	}
+	return &Transcript{[]string{header}}
+}
+
+// ReadTranscript -- parse a transcript written by WriteTo
+func ReadTranscript(r io.Reader) (*Transcript, error) {
+	t := &Transcript{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		t.lines = append(t.lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := t.Params(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Params -- the parameters of the recorded run
+func (t *Transcript) Params() (p TranscriptParams, err error) {
+	if len(t.lines) == 0 {
+		return p, ErrBadTranscript
+	}
+	var seed string
+	_, err = fmt.Sscanf(t.lines[0], "params %s %d %d %d %d %d", &seed, &p.GroupSize, &p.Threshold, &p.Processes, &p.Groups, &p.Length)
+	if err != nil {
+		return p, ErrBadTranscript
+	}
+	b, err := hex.DecodeString(seed)
+	if err != nil || len(b) != bls.RandLength {
+		return p, ErrBadTranscript
+	}
+	copy(p.Seed[:], b)
+	f := strings.Fields(t.lines[0])
+	if len(f) < 7 {
+		return p, ErrBadTranscript
+	}
+	for _, opt := range f[7:] {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return p, ErrBadTranscript
+		}
+		switch kv[0] {
+		case "curve":
+			if _, ok := blscgo.Curves[kv[1]]; !ok {
+				return p, ErrBadTranscript
+			}
+			p.Curve = kv[1]
+		case "ids":
+			mode, ok := state.IDModes[kv[1]]
+			if !ok {
+				return p, ErrBadTranscript
+			}
+			p.IDMode = mode
+		case "refresh":
+			if _, err := fmt.Sscanf(kv[1], "%d", &p.Refresh); err != nil {
+				return p, ErrBadTranscript
+			}
+		case "proposers":
+			if _, err := fmt.Sscanf(kv[1], "%d", &p.Proposers); err != nil {
+				return p, ErrBadTranscript
+			}
+		case "notarize":
+			if _, err := fmt.Sscanf(kv[1], "%d", &p.Delay); err != nil {
+				return p, ErrBadTranscript
+			}
+			p.Notarize = true
+		case "form":
+			if _, err := fmt.Sscanf(kv[1], "%d", &p.Form); err != nil {
+				return p, ErrBadTranscript
+			}
+		default:
+			return p, ErrBadTranscript
+		}
+	}
+	return p, nil
+}
+
+// Len -- number of lines including the header
+func (t *Transcript) Len() int {
+	return len(t.lines)
+}
+
+// WriteTo -- write the transcript, one entry per line
+func (t *Transcript) WriteTo(w io.Writer) (n int64, err error) {
+	for _, l := range t.lines {
+		m, err := io.WriteString(w, l+"\n")
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Diff -- compare against another transcript, nil if both are identical
+func (t *Transcript) Diff(other *Transcript) error {
+	for i := 0; i < len(t.lines) || i < len(other.lines); i++ {
+		var want, got string
+		if i < len(t.lines) {
+			want = t.lines[i]
+		}
+		if i < len(other.lines) {
+			got = other.lines[i]
+		}
+		if want != got {
+			return &MismatchError{i + 1, want, got}
+		}
+	}
+	return nil
+}
+
+// record -- append a line, nothing is recorded without a transcript
+func (t *Transcript) record(kind string, fields ...interface{}) {
+	if t == nil {
+		return
+	}
+	s := make([]string, len(fields)+1)
+	s[0] = kind
+	for i, f := range fields {
+		s[i+1] = fmt.Sprint(f)
+	}
+	t.lines = append(t.lines, strings.Join(s, " "))
+}
+
+// Record -- run a simulation with the given parameters and return its transcript
+// The curve p.Curve has to be initialized by the caller.
+func Record(p TranscriptParams) (*Transcript, error) {
+	t := NewTranscript(p)
+	sim, err := NewBlockchainSimulator(p.Seed, p.GroupSize, p.Threshold, p.Processes, p.Groups, p.Config(t))
+	if err != nil {
+		return nil, err
+	}
+	if err := sim.Advance(p.Length, false); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Replay -- re-run the simulation recorded in r and compare the transcripts
+// The simulation runs on the curve of the transcript, the curve of the caller is initialized again on return.
+// Returns a *MismatchError on the first differing line.
+func Replay(r io.Reader) error {
+	want, err := ReadTranscript(r)
+	if err != nil {
+		return err
+	}
+	p, err := want.Params()
+	if err != nil {
+		return err
+	}
+	if c, ok := blscgo.Curves[p.Curve]; ok && c != blscgo.Curve() {
+		if prev := blscgo.Curve(); prev >= 0 {
+			defer blscgo.Init(prev)
+		}
+		blscgo.Init(c)
+	}
+	got, err := Record(p)
+	if err != nil {
+		return err
+	}
+	return want.Diff(got)
+}
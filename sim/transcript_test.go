@@ -0,0 +1,212 @@
+package sim
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"dfinity/beacon/state"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden transcript in testdata")
+
+// goldenFile -- transcript of a small run on bn254, recorded with -update
+const goldenFile = "testdata/golden_bn254.txt"
+
+var goldenParams = TranscriptParams{
+	Seed:      bls.RandFromBytes([]byte("DFINITY")),
+	GroupSize: 3,
+	Threshold: 2,
+	Processes: 8,
+	Groups:    5,
+	Length:    10,
+	Curve:     "bn254",
+}
+
+func TestTranscriptDeterministic(t *testing.T) {
+	t1, err := Record(goldenParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := Record(goldenParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := t1.Diff(t2); err != nil {
+		t.Fatal(err)
+	}
+
+	// a round trip through the file format gives the same transcript
+	var buf bytes.Buffer
+	if _, err := t1.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	t3, err := ReadTranscript(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := t1.Diff(t3); err != nil {
+		t.Fatal(err)
+	}
+	p, err := t3.Params()
+	if err != nil || p != goldenParams {
+		t.Error("Params do not survive round trip", p, err)
+	}
+
+	// a tampered transcript is detected
+	t3.lines[t3.Len()-1] += "00"
+	if _, ok := t1.Diff(t3).(*MismatchError); !ok {
+		t.Error("Tampered transcript not detected")
+	}
+}
+
+func TestTranscriptGolden(t *testing.T) {
+	if *update {
+		tr, err := Record(goldenParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := tr.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(goldenFile), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(goldenFile, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(goldenFile)
+	if os.IsNotExist(err) {
+		t.Fatal("no golden transcript, create it with: go test ./sim -run TestTranscriptGolden -update")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := Replay(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordReplayed -- record a run with parameters p and check that it replays and keeps its parameters
+func recordReplayed(t *testing.T, p TranscriptParams) *Transcript {
+	tr, err := Record(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := tr.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replay(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if q, err := tr.Params(); err != nil || q != p {
+		t.Error("Params do not survive round trip", q, err)
+	}
+	return tr
+}
+
+func TestReplayRestoresCurve(t *testing.T) {
+	p := goldenParams
+	p.Curve, p.Length = "bn382_1", 2
+	blscgo.Init(blscgo.CurveFp382_1)
+	tr, err := Record(p)
+	blscgo.Init(blscgo.CurveFp254BNb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := tr.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replay(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if blscgo.Curve() != blscgo.CurveFp254BNb {
+		t.Error("Replay did not restore the curve", blscgo.Curve())
+	}
+}
+
+// linesWith -- the lines of the transcript with the given prefix
+func linesWith(tr *Transcript, prefix string) (lines []string) {
+	for _, l := range tr.lines {
+		if strings.HasPrefix(l, prefix) {
+			lines = append(lines, l)
+		}
+	}
+	return
+}
+
+func TestTranscriptIDByIndex(t *testing.T) {
+	p := goldenParams
+	p.IDMode = state.IDByIndex
+	byIndex := recordReplayed(t, p)
+	byAddress, err := Record(goldenParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the IDs change the shares but not the group keys, so the beacon is the same
+	var shares, beacons int
+	for i := 1; i < byIndex.Len(); i++ {
+		a, b := byIndex.lines[i], byAddress.lines[i]
+		switch {
+		case strings.HasPrefix(a, "share ") && a != b:
+			shares++
+		case strings.HasPrefix(a, "beacon "):
+			if a != b {
+				t.Fatalf("beacon differs between ID modes:\n  %s\n  %s", a, b)
+			}
+			beacons++
+		}
+	}
+	if shares == 0 || beacons != int(p.Length) {
+		t.Error("unexpected transcript, shares differing:", shares, "beacons:", beacons)
+	}
+}
+
+func TestTranscriptRefresh(t *testing.T) {
+	p := goldenParams
+	p.Refresh = 3
+	refreshed := recordReplayed(t, p)
+	plain, err := Record(goldenParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// refreshes change the shares but not the group keys, so the beacon is the same
+	want, got := linesWith(plain, "beacon "), linesWith(refreshed, "beacon ")
+	if len(got) != int(p.Length) || strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Error("beacon differs with refreshes")
+	}
+	// 3 refreshes of 5 groups with 3 members each, one share per pair of members
+	if n := len(linesWith(refreshed, "rshare ")); n != 3*5*3*3 {
+		t.Error("unexpected number of refresh shares:", n)
+	}
+}
+
+func TestTranscriptOptions(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		set    func(*TranscriptParams)
+		prefix string
+		n      int
+	}{
+		// every proposer records a candidate in every round
+		{"proposers", func(p *TranscriptParams) { p.Proposers = 3 }, "candidate ", int(goldenParams.Length) * 3},
+		// every formed group is certified
+		{"form", func(p *TranscriptParams) { p.Form = 2 }, "cert ", int(goldenParams.Length) / 2},
+	} {
+		p := goldenParams
+		c.set(&p)
+		if n := len(linesWith(recordReplayed(t, p), c.prefix)); n != c.n {
+			t.Errorf("%s: unexpected number of %q lines: %d", c.name, c.prefix, n)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package state
+
+import (
+	"dfinity/beacon/bls"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Groups also sign application messages. The group does not sign the message itself but a hash of it
+// under a domain tag, so an application signature can never be a beacon output, a notarization or a
+// signature for another application: all of these sign values that nobody can choose the preimage of.
+
+// AppMessage -- the value that a group signs for the application message msg in the given domain
+func AppMessage(domain string, msg []byte) []byte {
+	h := keccak(lengthPrefixed([]byte("application"), []byte(domain), msg))
+	return h[:]
+}
+
+// VerifyAppSig -- verify the signature of the group registered under a on an application message
+func (s State) VerifyAppSig(a common.Address, domain string, msg []byte, sig bls.Signature) bool {
+	if _, ok := s.groups[a]; !ok {
+		return false
+	}
+	return bls.VerifySig(s.GroupPubkey(a), AppMessage(domain, msg), sig)
+}
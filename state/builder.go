@@ -0,0 +1,131 @@
+package state
+
+import (
+	"dfinity/beacon/bls"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Builder -- derives a new State from a parent State, which is never modified
+// The maps of the parent are copied on the first change to them, unchanged maps are shared with the parent.
+type Builder struct {
+	s State
+	// the parent, whose groups certify new groups
+	parent State
+	// whether s.nodes / s.groups are private copies owned by the builder
+	ownNodes, ownGroups bool
+}
+
+// NewBuilder -- start a new state from parent, with the parent's nodes, groups and signature
+func NewBuilder(parent State) *Builder {
+	if parent.nodes == nil || parent.groups == nil {
+		// the zero State
+		return &Builder{s: NewState(), parent: NewState(), ownNodes: true, ownGroups: true}
+	}
+	b := &Builder{s: parent, parent: parent}
+	// certificates belong to the block that registers the groups
+	b.s.certs = nil
+	return b
+}
+
+// Build -- the new state
+// The Merkle roots are recomputed for the maps that changed.
+// The builder can be used further, later changes do not affect the returned State.
+func (b *Builder) Build() State {
+	if b.ownNodes {
+		b.s.nodeRoot = merkleRoot(nodeLeaves(b.s.nodes), 0)
+	}
+	if b.ownGroups {
+		b.s.groupRoot = merkleRoot(groupLeaves(b.s.groups), 0)
+	}
+	b.ownNodes, b.ownGroups = false, false
+	return b.s
+}
+
+// Mutators
+
+// AddNode --
+func (b *Builder) AddNode(n Node) error {
+	if !n.hasPop() {
+		logger.Error("rejected node", "addr", n.Address().Hex(), "err", ErrInvalidPop)
+		return ErrInvalidPop
+	}
+	b.writeNodes()[n.Address()] = n
+	return nil
+}
+
+// AddGroup --
+func (b *Builder) AddGroup(g Group) error {
+	if !g.isValid() {
+		logger.Error("rejected group", "addr", g.Address().Hex(), "err", ErrInvalidGroup)
+		return ErrInvalidGroup
+	}
+	b.writeGroups()[g.Address()] = g
+	return nil
+}
+
+// UpdateGroup -- replace a registered group, e.g. after a share refresh, keeping its pubkey
+func (b *Builder) UpdateGroup(g Group) error {
+	return b.ReplaceGroup(g.Address(), g)
+}
+
+// ReplaceGroup -- replace the group registered under old by g, e.g. after a handover to new members
+// The pubkey has to stay the same.
+func (b *Builder) ReplaceGroup(old common.Address, g Group) error {
+	prev, ok := b.s.groups[old]
+	if !ok || prev.Pubkey().String() != g.Pubkey().String() {
+		logger.Error("rejected group replacement", "addr", old.Hex(), "err", ErrInvalidGroup)
+		return ErrInvalidGroup
+	}
+	groups := b.writeGroups()
+	delete(groups, old)
+	groups[g.Address()] = g
+	return nil
+}
+
+// Certify -- record the certificate of a group registered by the builder
+// The signer has to be a group of the parent state.
+func (b *Builder) Certify(c Certificate) error {
+	g, ok := b.s.groups[c.Group]
+	signer, known := b.parent.groups[c.Signer]
+	if !ok || !known || g.Pubkey().String() != c.Pubkey.String() || !c.Verify(signer.Pubkey()) {
+		logger.Error("rejected group certificate", "addr", c.Group.Hex(), "signer", c.Signer.Hex(), "err", ErrInvalidCertificate)
+		return ErrInvalidCertificate
+	}
+	// never append to the slice of another state
+	b.s.certs = append(append([]Certificate{}, b.s.certs...), c)
+	return nil
+}
+
+// SetSignature --
+func (b *Builder) SetSignature(sig bls.Signature) {
+	b.s.sig = sig
+}
+
+// SetProposer -- set the node that proposed the block
+func (b *Builder) SetProposer(a common.Address) {
+	b.s.proposer = a
+}
+
+// writeNodes -- the node map, copied first if it is shared
+func (b *Builder) writeNodes() map[common.Address]Node {
+	if !b.ownNodes {
+		nodes := make(map[common.Address]Node, len(b.s.nodes)+1)
+		for a, n := range b.s.nodes {
+			nodes[a] = n
+		}
+		b.s.nodes, b.ownNodes = nodes, true
+	}
+	return b.s.nodes
+}
+
+// writeGroups -- the group map, copied first if it is shared
+func (b *Builder) writeGroups() map[common.Address]Group {
+	if !b.ownGroups {
+		groups := make(map[common.Address]Group, len(b.s.groups)+1)
+		for a, g := range b.s.groups {
+			groups[a] = g
+		}
+		b.s.groups, b.ownGroups = groups, true
+	}
+	return b.s.groups
+}
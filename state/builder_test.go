@@ -0,0 +1,65 @@
+package state
+
+import (
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestBuilderCopyOnWrite(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	nodes := make([]Node, 3)
+	for i := range nodes {
+		var err error
+		if nodes[i], err = NodeFromSeckey(bls.SeckeyFromInt(int64(i + 1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := NewBuilder(NewState())
+	for _, n := range nodes[:2] {
+		if err := b.AddNode(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g, _ := NewGroup([]common.Address{nodes[0].Address(), nodes[1].Address()}, 1, IDByAddress, 0, 0)
+	if err := b.AddGroup(g); err != nil {
+		t.Fatal(err)
+	}
+	genesis := b.Build()
+
+	// a signature-only block shares both maps with its parent
+	b = NewBuilder(genesis)
+	sig, _ := bls.Sign(bls.SeckeyFromInt(1), []byte("hi"))
+	b.SetSignature(sig)
+	block := b.Build()
+	if block.Signature().String() != sig.String() || genesis.Signature().String() == sig.String() {
+		t.Error("SetSignature is wrong")
+	}
+
+	// changes in a derived state and in the builder after Build leave earlier states intact
+	b = NewBuilder(block)
+	if err := b.AddNode(nodes[2]); err != nil {
+		t.Fatal(err)
+	}
+	next := b.Build()
+	h, _ := NewGroup([]common.Address{nodes[1].Address(), nodes[2].Address()}, 1, IDByAddress, 2, 0)
+	if err := b.AddGroup(h); err != nil {
+		t.Fatal(err)
+	}
+	last := b.Build()
+	for i, c := range []struct {
+		s         State
+		nodes, gr int
+	}{{genesis, 2, 1}, {block, 2, 1}, {next, 3, 1}, {last, 3, 2}} {
+		if len(c.s.nodes) != c.nodes || len(c.s.groups) != c.gr {
+			t.Errorf("state %d has %d nodes and %d groups, want %d and %d", i, len(c.s.nodes), len(c.s.groups), c.nodes, c.gr)
+		}
+	}
+	if _, ok := last.Group(h.Address()); !ok {
+		t.Error("Group not found")
+	}
+	if _, ok := genesis.Node(nodes[2].Address()); ok {
+		t.Error("Node added later is visible in the genesis state")
+	}
+}
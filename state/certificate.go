@@ -0,0 +1,91 @@
+package state
+
+import (
+	"dfinity/beacon/bls"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Group certificates
+//
+// Every group registered after the first block is certified by an existing group: a threshold signature
+// on the new group's address and pubkey, recorded in the block that registers it. Following the signers of
+// the certificates leads back to groups of the genesis block. A light client that trusts only the genesis
+// group keys learns the key of any later group from this chain of certificates, without the states in between.
+
+// Certificate -- the signature of group Signer, registered in the parent state, on the pubkey of group Group
+type Certificate struct {
+	Group  common.Address
+	Pubkey bls.Pubkey
+	Signer common.Address
+	Sig    bls.Signature
+}
+
+// CertificateRecord --
+type CertificateRecord struct {
+	Group  string `json:"grp"`
+	Pubkey string `json:"pub"`
+	Signer string `json:"signer"`
+	Sig    string `json:"sig"`
+}
+
+// CertificateMessage -- the value that the certifying group signs for the group registered under a with pubkey pub
+func CertificateMessage(a common.Address, pub bls.Pubkey) []byte {
+	h := keccak(lengthPrefixed([]byte("certificate"), a[:], []byte(pub.String())))
+	return h[:]
+}
+
+// Verify -- verify the certificate against the pubkey of its signer
+func (c Certificate) Verify(signer bls.Pubkey) bool {
+	return bls.VerifySig(signer, CertificateMessage(c.Group, c.Pubkey), c.Sig)
+}
+
+// Record --
+func (c Certificate) Record() CertificateRecord {
+	return CertificateRecord{c.Group.Hex(), c.Pubkey.String(), c.Signer.Hex(), c.Sig.String()}
+}
+
+// CertificateFromRecord --
+func CertificateFromRecord(r CertificateRecord) Certificate {
+	return Certificate{common.HexToAddress(r.Group), bls.PubkeyFromString(r.Pubkey), common.HexToAddress(r.Signer), bls.SignatureFromString(r.Sig)}
+}
+
+// VerifyCertificateChain -- the pubkey of the group registered under a, learnt from trusted keys and a certificate chain
+// The chain starts with a certificate signed by a trusted group and ends with the certificate of a, each
+// certificate signed by the group certified before. An empty chain is valid for trusted groups.
+func VerifyCertificateChain(trusted bls.PubkeyMap, a common.Address, chain []Certificate) (bls.Pubkey, bool) {
+	if len(chain) == 0 {
+		pub, ok := trusted[a]
+		return pub, ok
+	}
+	signer, ok := trusted[chain[0].Signer]
+	if !ok {
+		return bls.Pubkey{}, false
+	}
+	for i, c := range chain {
+		if i > 0 && c.Signer != chain[i-1].Group {
+			return bls.Pubkey{}, false
+		}
+		if !c.Verify(signer) {
+			return bls.Pubkey{}, false
+		}
+		signer = c.Pubkey
+	}
+	if chain[len(chain)-1].Group != a {
+		return bls.Pubkey{}, false
+	}
+	return signer, true
+}
+
+// Certificates -- the certificates of the groups registered in the block
+func (s State) Certificates() []Certificate {
+	return append([]Certificate{}, s.certs...)
+}
+
+// GroupPubkeys -- the pubkeys of all groups, e.g. the keys a light client trusts in the genesis block
+func (s State) GroupPubkeys() bls.PubkeyMap {
+	pubs := make(bls.PubkeyMap, len(s.groups))
+	for a, g := range s.groups {
+		pubs[a] = g.pub
+	}
+	return pubs
+}
@@ -3,10 +3,11 @@ package state
 import (
 	"dfinity/beacon/bls"
 	dfn "dfinity/beacon/common"
+	"encoding/binary"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
-	"log"
+	"math"
 )
 
 // Group -- encodes all data of a group as recorded on the blockchain
@@ -15,47 +16,298 @@ type Group struct {
 	// group pubkey
 	pub       bls.Pubkey
 	threshold uint16
+	// block height at which the group was formed, and a nonce to tell apart groups formed at the same height
+	height uint64
+	nonce  uint64
+	// identifier, derived from members, threshold, height and nonce in NewGroup
+	addr common.Address
+	// combined verification vector of all members' contributions, vvec[0] is the group pubkey
+	vvec bls.VerificationVector
+	// the members' IDs for secret sharing
+	idmode IDMode
+	ids    map[common.Address]bls.ID
 }
 
-// NewGroup -- create a new Group struct with list of members and empty pubkey
-func NewGroup(addresses []common.Address, k uint16) Group {
-	return Group{addresses, bls.Pubkey{}, k}
+// IDMode -- how the IDs for secret sharing are assigned to the members of a group
+type IDMode int
+
+const (
+	// IDByAddress -- the ID of a member is its address as integer
+	IDByAddress IDMode = iota
+	// IDByIndex -- the ID of a member is its position 1..n in the list of members sorted by address
+	IDByIndex
+)
+
+// IDModes -- IDModes by name
+var IDModes = map[string]IDMode{
+	"address": IDByAddress,
+	"index":   IDByIndex,
 }
 
-// SetPubkey -- set the group's pubkey and threshold
-func (g *Group) SetPubkey(pub bls.Pubkey, k uint16) {
-	g.pub = pub
-	g.threshold = k
+// String --
+func (m IDMode) String() string {
+	for name, mode := range IDModes {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("IDMode(%d)", int(m))
 }
 
-// Getters
+// GroupRecord -- machine-readable representation of a Group
+type GroupRecord struct {
+	Address   string   `json:"addr"`
+	Pubkey    string   `json:"pub"`
+	Threshold int      `json:"k"`
+	Height    uint64   `json:"h"`
+	Nonce     uint64   `json:"nonce"`
+	Members   []string `json:"mem"`
+	Vvec      []string `json:"vvec,omitempty"`
+	IDMode    string   `json:"ids"`
+}
 
-// Address - the group address
-func (g Group) Address() (a common.Address) {
-	// hash of all member addresses
-	d := sha3.NewKeccak256()
-	addresses := g.members
-	dfn.SortAddresses(addresses)
-	var err error
-	for _, addr := range addresses {
-		_, err = d.Write(addr[:])
-		if err != nil {
-			log.Fatalln("Error when calling Keccak256")
+// NewGroup -- create a new Group struct with list of members, threshold, formation height and nonce, and empty pubkey
+// Fails if the threshold is not between 1 and the number of members, or the members' IDs are not valid and distinct.
+func NewGroup(addresses []common.Address, k uint16, mode IDMode, height uint64, nonce uint64) (Group, error) {
+	if k < 1 || int(k) > len(addresses) {
+		logger.Error("invalid group threshold", "n", len(addresses), "k", k)
+		return Group{}, ErrInvalidGroup
+	}
+	sorted := append([]common.Address{}, addresses...)
+	dfn.SortAddresses(sorted)
+	ids := make(map[common.Address]bls.ID, len(sorted))
+	idlist := make([]bls.ID, len(sorted))
+	for i, a := range sorted {
+		switch mode {
+		case IDByAddress:
+			idlist[i] = bls.IDFromAddress(a)
+		case IDByIndex:
+			idlist[i] = bls.IDFromIndex(i + 1)
+		default:
+			return Group{}, ErrInvalidGroup
+		}
+		ids[a] = idlist[i]
+	}
+	if err := bls.ValidateIDs(idlist); err != nil {
+		return Group{}, err
+	}
+	// duplicate addresses have distinct indices
+	if len(ids) != len(sorted) {
+		logger.Error("duplicate group member", "n", len(sorted))
+		return Group{}, bls.ErrDuplicateID
+	}
+	g := Group{
+		members:   append([]common.Address{}, addresses...),
+		threshold: k,
+		height:    height,
+		nonce:     nonce,
+		idmode:    mode,
+		ids:       ids,
+	}
+	g.addr = groupAddress(sorted, k, height, nonce)
+	return g, nil
+}
+
+// GroupFromRecord -- inverse of Record
+// Fails if the address does not match members, threshold, height and nonce, or the pubkey does not match the verification vector.
+// The range of the threshold is checked by NewGroup.
+func GroupFromRecord(r GroupRecord) (Group, error) {
+	mode, ok := IDModes[r.IDMode]
+	if !ok || r.Threshold < 0 || r.Threshold > math.MaxUint16 {
+		logger.Error("invalid group record", "addr", r.Address, "k", r.Threshold, "ids", r.IDMode)
+		return Group{}, ErrInvalidRecord
+	}
+	members := make([]common.Address, len(r.Members))
+	for i, m := range r.Members {
+		members[i] = common.HexToAddress(m)
+	}
+	g, err := NewGroup(members, uint16(r.Threshold), mode, r.Height, r.Nonce)
+	if err != nil {
+		return Group{}, err
+	}
+	if g.Address().Hex() != r.Address {
+		logger.Error("group record does not match its address", "addr", r.Address)
+		return Group{}, ErrInvalidRecord
+	}
+	if len(r.Vvec) == 0 {
+		g.SetPubkey(bls.PubkeyFromString(r.Pubkey))
+		return g, nil
+	}
+	vvec := make(bls.VerificationVector, len(r.Vvec))
+	for i, s := range r.Vvec {
+		vvec[i] = bls.PubkeyFromString(s)
+		if _, err := vvec[i].PublicKey(); err != nil {
+			return Group{}, err
 		}
 	}
+	if err := g.SetVerificationVector(vvec); err != nil {
+		return Group{}, err
+	}
+	if g.pub.String() != r.Pubkey {
+		logger.Error("group pubkey does not match verification vector", "addr", r.Address)
+		return Group{}, ErrInvalidRecord
+	}
+	return g, nil
+}
+
+// groupAddress -- hash of the canonical encoding of a group's identity
+// The encoding is the number of members, the sorted member addresses, the threshold, the height and the nonce,
+// all integers big-endian with fixed width.
+func groupAddress(sorted []common.Address, k uint16, height uint64, nonce uint64) common.Address {
+	n := len(sorted) * common.AddressLength
+	b := make([]byte, 4+n+2+8+8)
+	binary.BigEndian.PutUint32(b, uint32(len(sorted)))
+	for i, a := range sorted {
+		copy(b[4+i*common.AddressLength:], a[:])
+	}
+	binary.BigEndian.PutUint16(b[4+n:], k)
+	binary.BigEndian.PutUint64(b[6+n:], height)
+	binary.BigEndian.PutUint64(b[14+n:], nonce)
+	d := sha3.NewKeccak256()
+	// Write on a hash.Hash never returns an error
+	d.Write(b)
 	var h common.Hash
 	d.Sum(h[:0])
 	return common.BytesToAddress(h[:])
 }
 
+// SetPubkey -- set the group's pubkey
+func (g *Group) SetPubkey(pub bls.Pubkey) {
+	g.pub = pub
+}
+
+// SetVerificationVector -- set the group's combined verification vector, and the pubkey from it
+// The length of the vector has to be the group's threshold.
+func (g *Group) SetVerificationVector(vvec bls.VerificationVector) error {
+	if vvec.Threshold() != int(g.threshold) {
+		logger.Error("verification vector does not match threshold", "addr", g.addr.Hex(), "k", g.threshold, "len", vvec.Threshold())
+		return ErrInvalidGroup
+	}
+	g.vvec = vvec
+	g.SetPubkey(vvec.Pubkey())
+	return nil
+}
+
+// Refresh -- the group after its members added a sharing of zero with verification vector delta to their shares
+// The pubkey stays the same, only the verification vector changes. The receiver is not modified.
+func (g Group) Refresh(delta bls.VerificationVector) (Group, error) {
+	if !delta.IsZeroSharing() || g.vvec == nil {
+		logger.Error("rejected refresh", "addr", g.Address().Hex(), "err", ErrInvalidRefresh)
+		return g, ErrInvalidRefresh
+	}
+	vvec, err := bls.CombineVerificationVectors([]bls.VerificationVector{g.vvec, delta})
+	if err != nil {
+		return g, err
+	}
+	g.vvec = vvec
+	return g, nil
+}
+
+// Handover -- the group next, formed by resharing this group's key to next's members, with verification vector vvec
+// The vector has to keep the group pubkey and match next's threshold. The receiver is not modified.
+func (g Group) Handover(next Group, vvec bls.VerificationVector) (Group, error) {
+	if vvec.Pubkey().String() != g.pub.String() || vvec.Threshold() != next.Threshold() {
+		logger.Error("rejected handover", "addr", g.Address().Hex(), "err", ErrInvalidHandover)
+		return g, ErrInvalidHandover
+	}
+	err := next.SetVerificationVector(vvec)
+	return next, err
+}
+
+// Getters
+
+// Address - the group identifier, a hash over members, threshold, formation height and nonce
+// Groups with the same members but different threshold, height or nonce have different addresses.
+func (g Group) Address() common.Address {
+	return g.addr
+}
+
+// Height -- the block height at which the group was formed
+func (g Group) Height() uint64 {
+	return g.height
+}
+
+// Nonce -- the nonce that tells apart groups formed at the same height
+func (g Group) Nonce() uint64 {
+	return g.nonce
+}
+
 // Pubkey -- the group pubkey
 func (g Group) Pubkey() bls.Pubkey {
 	return g.pub
 }
 
+// VerificationVector -- the combined verification vector, nil if unknown
+func (g Group) VerificationVector() bls.VerificationVector {
+	return g.vvec
+}
+
+// IDMode -- how the members' IDs are assigned
+func (g Group) IDMode() IDMode {
+	return g.idmode
+}
+
+// MemberID -- the ID of a member for secret sharing
+func (g Group) MemberID(addr common.Address) (bls.ID, error) {
+	id, ok := g.ids[addr]
+	if !ok {
+		return bls.ID{}, ErrNotMember
+	}
+	return id, nil
+}
+
+// MemberIDs -- the IDs of a list of members
+func (g Group) MemberIDs(addrs []common.Address) ([]bls.ID, error) {
+	ids := make([]bls.ID, len(addrs))
+	for i, a := range addrs {
+		var err error
+		if ids[i], err = g.MemberID(a); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// LagrangeBasis -- the basis for recovery from the shares of the given members
+func (g Group) LagrangeBasis(addrs []common.Address) (bls.LagrangeBasis, error) {
+	ids, err := g.MemberIDs(addrs)
+	if err != nil {
+		return bls.LagrangeBasis{}, err
+	}
+	return bls.NewLagrangeBasis(ids)
+}
+
+// MemberPubkey -- the pubkey share of a member, derived from the verification vector
+func (g Group) MemberPubkey(addr common.Address) (bls.Pubkey, error) {
+	if g.vvec == nil {
+		return bls.Pubkey{}, ErrNoVvec
+	}
+	id, err := g.MemberID(addr)
+	if err != nil {
+		return bls.Pubkey{}, err
+	}
+	return g.vvec.PubkeyAt(id)
+}
+
+// VerifySigShare -- check a member's signature share on msg against the verification vector
+func (g Group) VerifySigShare(addr common.Address, msg []byte, sig bls.Signature) bool {
+	pub, err := g.MemberPubkey(addr)
+	if err != nil {
+		return false
+	}
+	return bls.VerifySig(pub, msg, sig)
+}
+
+// Hash -- the value of the group in the state's Merkle tree
+// It is a hash over the address, which commits to members, threshold, height and nonce, and over pubkey,
+// verification vector and ID mode.
+func (g Group) Hash() common.Hash {
+	return keccak(lengthPrefixed(g.addr[:], []byte(g.pub.String()), []byte(g.vvec.String()), []byte(g.idmode.String())))
+}
+
 // Members -- the list of members
 func (g Group) Members() []common.Address {
-	return g.members
+	return append([]common.Address{}, g.members...)
 }
 
 // Threshold -- the threshold used in the setup
@@ -92,6 +344,15 @@ func (g Group) String() string {
 	return fmt.Sprintf("GrpR: (addr)%x (pub)%.8s (n)%d (k)%d (mem)%s", a[:2], g.pub.String(), len(g.members), g.threshold, mem)
 }
 
+// Record -- full (untruncated) representation for structured output
+func (g Group) Record() GroupRecord {
+	mem := make([]string, len(g.members))
+	for i, m := range g.members {
+		mem[i] = m.Hex()
+	}
+	return GroupRecord{g.Address().Hex(), g.pub.String(), int(g.threshold), g.height, g.nonce, mem, g.vvec.Strings(), g.idmode.String()}
+}
+
 // isValid --
 /* TODO: check if group pubkey is individually signed by enough group members */
 func (g Group) isValid() bool {
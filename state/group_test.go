@@ -0,0 +1,105 @@
+package state
+
+import (
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestNewGroupIDs(t *testing.T) {
+	a, b, c := common.Address{3}, common.Address{1}, common.Address{2}
+	g, err := NewGroup([]common.Address{a, b, c}, 2, IDByIndex, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// indices follow the sorted addresses
+	for i, addr := range []common.Address{b, c, a} {
+		id, err := g.MemberID(addr)
+		if err != nil || !id.Equal(bls.IDFromIndex(i+1)) {
+			t.Error("Wrong ID for member", i, id, err)
+		}
+	}
+	if _, err := g.MemberID(common.Address{4}); err != ErrNotMember {
+		t.Error("Expected ErrNotMember, got", err)
+	}
+	if _, err := NewGroup([]common.Address{a, b, a}, 2, IDByIndex, 0, 0); err != bls.ErrDuplicateID {
+		t.Error("Expected ErrDuplicateID, got", err)
+	}
+	if _, err := NewGroup([]common.Address{a, b, a}, 2, IDByAddress, 0, 0); err != bls.ErrDuplicateID {
+		t.Error("Expected ErrDuplicateID, got", err)
+	}
+	if _, err := NewGroup([]common.Address{a, {}}, 2, IDByAddress, 0, 0); err != bls.ErrInvalidID {
+		t.Error("Expected ErrInvalidID for the zero address, got", err)
+	}
+	for _, k := range []uint16{0, 4} {
+		if _, err := NewGroup([]common.Address{a, b, c}, k, IDByIndex, 0, 0); err != ErrInvalidGroup {
+			t.Error("Expected ErrInvalidGroup for threshold", k, "got", err)
+		}
+	}
+}
+
+func TestGroupRefresh(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	g, err := NewGroup([]common.Address{{1}, {2}, {3}}, 2, IDByIndex, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vvec, _ := bls.VerificationVectorFromSeckeys([]bls.Seckey{bls.SeckeyFromInt(5), bls.SeckeyFromInt(7)})
+	if err := g.SetVerificationVector(vvec); err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder(NewState())
+	if err := b.AddGroup(g); err != nil {
+		t.Fatal(err)
+	}
+	prev := b.Build()
+
+	zero, _ := bls.VerificationVectorFromSeckeys([]bls.Seckey{bls.SeckeyFromInt(0), bls.SeckeyFromInt(3)})
+	refreshed, err := g.Refresh(zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Pubkey().String() != g.Pubkey().String() || refreshed.VerificationVector().String() == vvec.String() {
+		t.Error("Refresh has to change the verification vector but not the pubkey")
+	}
+	if g.VerificationVector().String() != vvec.String() {
+		t.Error("Refresh modified the receiver")
+	}
+	if _, err := g.Refresh(vvec); err != ErrInvalidRefresh {
+		t.Error("Expected ErrInvalidRefresh, got", err)
+	}
+	b = NewBuilder(prev)
+	if err := b.UpdateGroup(refreshed); err != nil {
+		t.Fatal(err)
+	}
+	s := b.Build()
+	if prev.groups[g.Address()].VerificationVector().String() != vvec.String() {
+		t.Error("UpdateGroup modified a previous state")
+	}
+	if s.groups[g.Address()].VerificationVector().String() != refreshed.VerificationVector().String() {
+		t.Error("UpdateGroup did not update the group")
+	}
+}
+
+func TestGroupAddress(t *testing.T) {
+	members := []common.Address{{3}, {1}, {2}}
+	g, _ := NewGroup(members, 2, IDByAddress, 5, 0)
+	perm, _ := NewGroup([]common.Address{{2}, {3}, {1}}, 2, IDByAddress, 5, 0)
+	if g.Address() != perm.Address() {
+		t.Error("Group address depends on the order of members")
+	}
+	for _, other := range []struct {
+		k             uint16
+		height, nonce uint64
+	}{{3, 5, 0}, {2, 6, 0}, {2, 5, 1}} {
+		h, _ := NewGroup(members, other.k, IDByAddress, other.height, other.nonce)
+		if h.Address() == g.Address() {
+			t.Error("Group address does not cover", other)
+		}
+	}
+	g.Address()
+	if m := g.Members(); m[0] != members[0] || m[1] != members[1] || m[2] != members[2] {
+		t.Error("Group members were reordered")
+	}
+}
@@ -0,0 +1,191 @@
+package state
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// History -- the states of consecutive blocks, with indices for historical queries
+// The indices are updated as blocks are appended. The first state is the genesis block or a snapshot.
+type History struct {
+	// height of states[0]
+	base   int
+	states []State
+	// signers[i] is the group that signed states[i], selected by states[i-1]; zero for the first state
+	signers []common.Address
+	// height at which a group became active, and at which it was removed
+	since, until map[common.Address]int
+	// groups of each node, in the order in which they became active
+	groupsOf map[common.Address][]common.Address
+	// number of blocks signed by each group
+	selected map[common.Address]int
+	// certificate of each group registered after the first state
+	certs map[common.Address]Certificate
+}
+
+// NewHistory -- a history starting with the state of the block at the given height
+func NewHistory(first State, height int) *History {
+	h := &History{
+		base:     height,
+		since:    make(map[common.Address]int),
+		until:    make(map[common.Address]int),
+		groupsOf: make(map[common.Address][]common.Address),
+		selected: make(map[common.Address]int),
+		certs:    make(map[common.Address]Certificate),
+	}
+	h.states = append(h.states, first)
+	h.signers = append(h.signers, common.Address{})
+	for _, a := range first.GroupAddressList() {
+		h.activate(a, first.groups[a], height)
+	}
+	return h
+}
+
+// Append -- add the state of the next block and update the indices
+func (h *History) Append(s State) {
+	prev := h.Tip()
+	height := h.Height() + 1
+	var signer common.Address
+	if len(prev.groups) > 0 {
+		signer = prev.SelectedGroupAddress()
+		h.selected[signer]++
+	}
+	h.states = append(h.states, s)
+	h.signers = append(h.signers, signer)
+	for _, c := range s.certs {
+		h.certs[c.Group] = c
+	}
+	// the group tree root is the same if and only if the groups are the same
+	if s.groupRoot == prev.groupRoot {
+		return
+	}
+	for a := range prev.groups {
+		if _, ok := s.groups[a]; !ok {
+			h.until[a] = height
+		}
+	}
+	for _, a := range s.GroupAddressList() {
+		if _, ok := prev.groups[a]; !ok {
+			h.activate(a, s.groups[a], height)
+		}
+	}
+}
+
+// activate -- record that g became active at height
+func (h *History) activate(a common.Address, g Group, height int) {
+	if _, ok := h.since[a]; ok {
+		// addresses include the formation height, so this only happens if a group is removed and added back
+		delete(h.until, a)
+		return
+	}
+	h.since[a] = height
+	for _, m := range g.members {
+		h.groupsOf[m] = append(h.groupsOf[m], a)
+	}
+}
+
+// Queries
+
+// Base -- the height of the first state
+func (h *History) Base() int {
+	return h.base
+}
+
+// Height -- the height of the last state
+func (h *History) Height() int {
+	return h.base + len(h.states) - 1
+}
+
+// Tip -- the last state
+func (h *History) Tip() State {
+	return h.states[len(h.states)-1]
+}
+
+// At -- the state at the given height, if it is in the history
+func (h *History) At(height int) (State, bool) {
+	if height < h.base || height > h.Height() {
+		return State{}, false
+	}
+	return h.states[height-h.base], true
+}
+
+// SignerAt -- the group that signed the block at the given height
+// Not known for the first state of the history, and the zero address if the previous state had no groups.
+func (h *History) SignerAt(height int) (common.Address, bool) {
+	if height <= h.base || height > h.Height() {
+		return common.Address{}, false
+	}
+	return h.signers[height-h.base], true
+}
+
+// ActiveSince -- the height at which the group became active
+// For groups of the first state this is the height of the first state.
+func (h *History) ActiveSince(a common.Address) (int, bool) {
+	height, ok := h.since[a]
+	return height, ok
+}
+
+// ActiveUntil -- the height of the first block without the group, if the group was removed
+func (h *History) ActiveUntil(a common.Address) (int, bool) {
+	height, ok := h.until[a]
+	return height, ok
+}
+
+// IsActive -- whether the group is in the state at the given height
+func (h *History) IsActive(a common.Address, height int) bool {
+	since, ok := h.since[a]
+	if !ok || height < since || height > h.Height() {
+		return false
+	}
+	until, removed := h.until[a]
+	return !removed || height < until
+}
+
+// GroupsOf -- the groups that node is a member of at the given height, in the order in which they became active
+func (h *History) GroupsOf(node common.Address, height int) []common.Address {
+	var groups []common.Address
+	for _, a := range h.groupsOf[node] {
+		if h.IsActive(a, height) {
+			groups = append(groups, a)
+		}
+	}
+	return groups
+}
+
+// SelectionCount -- the number of blocks in the history signed by the group
+func (h *History) SelectionCount(a common.Address) int {
+	return h.selected[a]
+}
+
+// SelectionCounts -- the number of blocks in the history signed by each group that signed at least one
+func (h *History) SelectionCounts() map[common.Address]int {
+	counts := make(map[common.Address]int, len(h.selected))
+	for a, c := range h.selected {
+		counts[a] = c
+	}
+	return counts
+}
+
+// Certificate -- the certificate of a group registered after the first state
+func (h *History) Certificate(a common.Address) (Certificate, bool) {
+	c, ok := h.certs[a]
+	return c, ok
+}
+
+// CertificateChain -- the certificates that lead from a group of the first state to the group registered under a
+// The chain is empty for groups of the first state, see VerifyCertificateChain.
+func (h *History) CertificateChain(a common.Address) ([]Certificate, bool) {
+	var chain []Certificate
+	for {
+		c, ok := h.certs[a]
+		if !ok {
+			break
+		}
+		// every certificate is signed by a group registered before, so the chain has no cycles
+		chain = append([]Certificate{c}, chain...)
+		a = c.Signer
+	}
+	if since, ok := h.since[a]; !ok || since != h.base {
+		return nil, false
+	}
+	return chain, true
+}
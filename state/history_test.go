@@ -0,0 +1,98 @@
+package state
+
+import (
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	secs := make([]bls.Seckey, 4)
+	addrs := make([]common.Address, len(secs))
+	b := NewBuilder(NewState())
+	for i := range secs {
+		secs[i] = bls.SeckeyFromInt(int64(i + 1))
+		n, _ := NodeFromSeckey(secs[i])
+		addrs[i] = n.Address()
+		b.AddNode(n)
+	}
+	g0, _ := NewGroup(addrs[:2], 1, IDByAddress, 0, 0)
+	g1, _ := NewGroup(addrs[1:3], 1, IDByAddress, 0, 1)
+	for i, g := range []*Group{&g0, &g1} {
+		pub, err := bls.PubkeyFromSeckey(secs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.SetPubkey(pub)
+	}
+	b.AddGroup(g0)
+	b.AddGroup(g1)
+	hist := NewHistory(b.Build(), 1)
+
+	// blocks 2..5, g0 is handed over to g2 in block 4
+	g2, _ := NewGroup(addrs[2:], 1, IDByAddress, 3, 0)
+	g2.SetPubkey(g0.Pubkey())
+	for h := 2; h <= 5; h++ {
+		b = NewBuilder(hist.Tip())
+		sig, _ := bls.Sign(secs[h%len(secs)], []byte{byte(h)})
+		b.SetSignature(sig)
+		if h == 4 {
+			if err := b.ReplaceGroup(g0.Address(), g2); err != nil {
+				t.Fatal(err)
+			}
+		}
+		prev := hist.Tip()
+		hist.Append(b.Build())
+		if signer, ok := hist.SignerAt(h); !ok || signer != prev.SelectedGroupAddress() {
+			t.Error("Wrong signer at height", h)
+		}
+	}
+
+	if hist.Base() != 1 || hist.Height() != 5 {
+		t.Fatal("Wrong heights", hist.Base(), hist.Height())
+	}
+	if _, ok := hist.SignerAt(1); ok {
+		t.Error("Signer of the first block is known")
+	}
+	if s, ok := hist.At(3); !ok || !hasGroup(s, g0) || hasGroup(s, g2) {
+		t.Error("Wrong state at height 3")
+	}
+	if _, ok := hist.At(6); ok {
+		t.Error("State beyond the tip")
+	}
+	if h, ok := hist.ActiveSince(g2.Address()); !ok || h != 4 {
+		t.Error("Wrong activation height", h)
+	}
+	if h, ok := hist.ActiveUntil(g0.Address()); !ok || h != 4 {
+		t.Error("Wrong removal height", h)
+	}
+	if _, ok := hist.ActiveUntil(g1.Address()); ok {
+		t.Error("Group removed that is still active")
+	}
+	if groups := hist.GroupsOf(addrs[2], 3); len(groups) != 1 || groups[0] != g1.Address() {
+		t.Error("Wrong groups of node at height 3", groups)
+	}
+	if groups := hist.GroupsOf(addrs[2], 5); len(groups) != 2 || groups[1] != g2.Address() {
+		t.Error("Wrong groups of node at height 5", groups)
+	}
+	if groups := hist.GroupsOf(addrs[0], 5); len(groups) != 0 {
+		t.Error("Node still member of a removed group", groups)
+	}
+	total := 0
+	for a, c := range hist.SelectionCounts() {
+		if c != hist.SelectionCount(a) {
+			t.Error("SelectionCounts differs from SelectionCount")
+		}
+		total += c
+	}
+	if total != 4 {
+		t.Error("Selections do not add up to the number of signed blocks", total)
+	}
+}
+
+func hasGroup(s State, g Group) bool {
+	_, ok := s.Group(g.Address())
+	return ok
+}
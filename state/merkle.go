@@ -0,0 +1,226 @@
+package state
+
+import (
+	"encoding/binary"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"sort"
+)
+
+// The state is committed to by two sparse Merkle trees, one over the nodes and one over the groups,
+// both keyed by address. The bits of the key, most significant first, give the path from the root.
+// A subtree without entries hashes to the zero hash, a subtree with a single entry hashes to that entry's leaf hash,
+// so the trees are deterministic and only as deep as needed to separate the keys.
+// The state root is the hash of the two tree roots and of the block signature, which determines the
+// beacon output of the block.
+
+// Domain separation of the hashed data
+const (
+	merkleLeafTag byte = 0
+	merkleNodeTag byte = 1
+	merkleRootTag byte = 2
+)
+
+// merkleLeaf -- an entry of a tree: the key and the hash of the value
+type merkleLeaf struct {
+	key   common.Address
+	value common.Hash
+}
+
+// Proof -- Merkle proof for the presence or absence of a node or group in a State
+type Proof struct {
+	// sibling hashes on the path from the tree root to the key
+	Siblings []common.Hash
+	// for absence: the entry in which the path ends, nil if it ends in an empty subtree
+	Leaf *ProofLeaf
+	// root of the other tree, of the groups for node proofs and of the nodes for group proofs
+	Other common.Hash
+	// hash of the block signature
+	Sig common.Hash
+}
+
+// ProofLeaf -- an entry of a tree as it appears in a Proof
+type ProofLeaf struct {
+	Key   common.Address
+	Value common.Hash
+}
+
+// keccak --
+func keccak(data ...[]byte) (h common.Hash) {
+	d := sha3.NewKeccak256()
+	for _, b := range data {
+		// Write on a hash.Hash never returns an error
+		d.Write(b)
+	}
+	d.Sum(h[:0])
+	return
+}
+
+// lengthPrefixed -- the concatenation of the fields, each preceded by its length as 4 bytes big-endian
+func lengthPrefixed(fields ...[]byte) []byte {
+	var b []byte
+	var l [4]byte
+	for _, f := range fields {
+		binary.BigEndian.PutUint32(l[:], uint32(len(f)))
+		b = append(b, l[:]...)
+		b = append(b, f...)
+	}
+	return b
+}
+
+// keyBit -- bit i of the key, counted from the most significant
+func keyBit(key common.Address, i int) byte {
+	return (key[i/8] >> uint(7-i%8)) & 1
+}
+
+// leafHash --
+func leafHash(key common.Address, value common.Hash) common.Hash {
+	return keccak([]byte{merkleLeafTag}, key[:], value[:])
+}
+
+// nodeHash -- hash of an inner node of a tree
+func nodeHash(left, right common.Hash) common.Hash {
+	return keccak([]byte{merkleNodeTag}, left[:], right[:])
+}
+
+// stateRoot -- the hash of the two tree roots and the signature hash
+func stateRoot(nodes, groups, sig common.Hash) common.Hash {
+	return keccak([]byte{merkleRootTag}, nodes[:], groups[:], sig[:])
+}
+
+// sortLeaves -- sort by key
+func sortLeaves(leaves []merkleLeaf) {
+	sort.Slice(leaves, func(i, j int) bool {
+		return leaves[i].key.Big().Cmp(leaves[j].key.Big()) < 0
+	})
+}
+
+// splitLeaves -- the leaves with bit i of the key 0 and 1, leaves have to be sorted and share the first i bits
+func splitLeaves(leaves []merkleLeaf, i int) (left, right []merkleLeaf) {
+	j := sort.Search(len(leaves), func(j int) bool { return keyBit(leaves[j].key, i) == 1 })
+	return leaves[:j], leaves[j:]
+}
+
+// merkleRoot -- root of the subtree at depth over the sorted leaves
+func merkleRoot(leaves []merkleLeaf, depth int) common.Hash {
+	switch len(leaves) {
+	case 0:
+		return common.Hash{}
+	case 1:
+		return leafHash(leaves[0].key, leaves[0].value)
+	}
+	left, right := splitLeaves(leaves, depth)
+	return nodeHash(merkleRoot(left, depth+1), merkleRoot(right, depth+1))
+}
+
+// merkleProve -- the siblings on the path to key in the tree over the sorted leaves,
+// and the entry in which the path ends, if any
+func merkleProve(leaves []merkleLeaf, key common.Address) (siblings []common.Hash, end *merkleLeaf) {
+	for depth := 0; len(leaves) > 1; depth++ {
+		left, right := splitLeaves(leaves, depth)
+		if keyBit(key, depth) == 0 {
+			siblings = append(siblings, merkleRoot(right, depth+1))
+			leaves = left
+		} else {
+			siblings = append(siblings, merkleRoot(left, depth+1))
+			leaves = right
+		}
+	}
+	if len(leaves) == 1 {
+		end = &leaves[0]
+	}
+	return
+}
+
+// merkleFold -- the tree root that the proof leads to from the entry (key, value),
+// or from the end of the path to key if value is nil, showing that key is absent
+// Fails if the proof is malformed.
+func merkleFold(key common.Address, value *common.Hash, p Proof) (h common.Hash, ok bool) {
+	if len(p.Siblings) > common.AddressLength*8 {
+		return h, false
+	}
+	switch {
+	case value != nil:
+		h = leafHash(key, *value)
+	case p.Leaf != nil:
+		// the entry found has to be a different one on the path to key
+		if p.Leaf.Key == key {
+			return h, false
+		}
+		for i := range p.Siblings {
+			if keyBit(p.Leaf.Key, i) != keyBit(key, i) {
+				return h, false
+			}
+		}
+		h = leafHash(p.Leaf.Key, p.Leaf.Value)
+	}
+	for i := len(p.Siblings) - 1; i >= 0; i-- {
+		if keyBit(key, i) == 0 {
+			h = nodeHash(h, p.Siblings[i])
+		} else {
+			h = nodeHash(p.Siblings[i], h)
+		}
+	}
+	return h, true
+}
+
+// proof -- the Proof for key in the tree over the sorted leaves
+func proof(leaves []merkleLeaf, key common.Address, other, sig common.Hash) Proof {
+	p := Proof{Other: other, Sig: sig}
+	siblings, end := merkleProve(leaves, key)
+	p.Siblings = siblings
+	if end != nil && end.key != key {
+		p.Leaf = &ProofLeaf{end.key, end.value}
+	}
+	return p
+}
+
+// nodeLeaves -- the sorted entries of the node tree
+func nodeLeaves(nodes map[common.Address]Node) []merkleLeaf {
+	leaves := make([]merkleLeaf, 0, len(nodes))
+	for a, n := range nodes {
+		leaves = append(leaves, merkleLeaf{a, n.Hash()})
+	}
+	sortLeaves(leaves)
+	return leaves
+}
+
+// groupLeaves -- the sorted entries of the group tree
+func groupLeaves(groups map[common.Address]Group) []merkleLeaf {
+	leaves := make([]merkleLeaf, 0, len(groups))
+	for a, g := range groups {
+		leaves = append(leaves, merkleLeaf{a, g.Hash()})
+	}
+	sortLeaves(leaves)
+	return leaves
+}
+
+// VerifyNodeProof -- check that the node n is registered under a in the state with the given root,
+// or if n is nil, that no node is registered under a
+func VerifyNodeProof(root common.Hash, a common.Address, n *Node, p Proof) bool {
+	var value *common.Hash
+	if n != nil {
+		if n.Address() != a {
+			return false
+		}
+		h := n.Hash()
+		value = &h
+	}
+	tree, ok := merkleFold(a, value, p)
+	return ok && stateRoot(tree, p.Other, p.Sig) == root
+}
+
+// VerifyGroupProof -- check that the group g is registered under a in the state with the given root,
+// or if g is nil, that no group is registered under a
+func VerifyGroupProof(root common.Hash, a common.Address, g *Group, p Proof) bool {
+	var value *common.Hash
+	if g != nil {
+		if g.Address() != a {
+			return false
+		}
+		h := g.Hash()
+		value = &h
+	}
+	tree, ok := merkleFold(a, value, p)
+	return ok && stateRoot(p.Other, tree, p.Sig) == root
+}
@@ -0,0 +1,113 @@
+package state
+
+import (
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	nodes := make([]Node, 12)
+	b := NewBuilder(NewState())
+	for i := range nodes {
+		var err error
+		if nodes[i], err = NodeFromSeckey(bls.SeckeyFromInt(int64(i + 1))); err != nil {
+			t.Fatal(err)
+		}
+		// the last node is not registered
+		if i < len(nodes)-1 {
+			if err := b.AddNode(nodes[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	groups := make([]Group, 4)
+	for i := range groups {
+		var err error
+		if groups[i], err = NewGroup([]common.Address{nodes[i].Address(), nodes[i+1].Address()}, 2, IDByAddress, 0, uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+		groups[i].SetPubkey(nodes[i].Pubkey())
+		if i < len(groups)-1 {
+			if err := b.AddGroup(groups[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	s := b.Build()
+	root := s.Root()
+
+	for i := range nodes {
+		a := nodes[i].Address()
+		p := s.ProveNode(a)
+		registered := i < len(nodes)-1
+		if VerifyNodeProof(root, a, &nodes[i], p) != registered {
+			t.Errorf("inclusion proof for node %d: got %v", i, !registered)
+		}
+		if VerifyNodeProof(root, a, nil, p) == registered {
+			t.Errorf("non-inclusion proof for node %d: got %v", i, registered)
+		}
+		if VerifyGroupProof(root, a, nil, p) {
+			t.Errorf("node proof %d verifies as group proof", i)
+		}
+	}
+	for i := range groups {
+		a := groups[i].Address()
+		p := s.ProveGroup(a)
+		registered := i < len(groups)-1
+		if VerifyGroupProof(root, a, &groups[i], p) != registered {
+			t.Errorf("inclusion proof for group %d: got %v", i, !registered)
+		}
+		if VerifyGroupProof(root, a, nil, p) == registered {
+			t.Errorf("non-inclusion proof for group %d: got %v", i, registered)
+		}
+	}
+
+	// a changed group pubkey is not proven
+	g := groups[0]
+	g.SetPubkey(nodes[5].Pubkey())
+	if VerifyGroupProof(root, g.Address(), &g, s.ProveGroup(g.Address())) {
+		t.Error("Proof verifies for a modified group")
+	}
+
+	// the root is deterministic and changes with the content
+	b2 := NewBuilder(NewState())
+	for i := len(nodes) - 2; i >= 0; i-- {
+		b2.AddNode(nodes[i])
+	}
+	for i := len(groups) - 2; i >= 0; i-- {
+		b2.AddGroup(groups[i])
+	}
+	if b2.Build().Root() != root {
+		t.Error("State root depends on insertion order")
+	}
+	b2.AddGroup(groups[len(groups)-1])
+	next := b2.Build()
+	if next.Root() == root {
+		t.Error("State root did not change with a new group")
+	}
+	// a signature-only block shares the tree roots of its parent, the state root commits to the signature
+	b3 := NewBuilder(next)
+	b3.SetSignature(bls.Signature{})
+	if b3.Build().Root() != next.Root() {
+		t.Error("State root changed without changes to nodes, groups and signature")
+	}
+	sig, err := bls.Sign(bls.SeckeyFromInt(1), []byte("block"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b3.SetSignature(sig)
+	signed := b3.Build()
+	if signed.Root() == next.Root() || signed.groupRoot != next.groupRoot || signed.nodeRoot != next.nodeRoot {
+		t.Error("State root does not commit to the signature")
+	}
+	a := groups[0].Address()
+	if !VerifyGroupProof(signed.Root(), a, &groups[0], signed.ProveGroup(a)) || VerifyGroupProof(signed.Root(), a, &groups[0], next.ProveGroup(a)) {
+		t.Error("Group proof does not commit to the signature")
+	}
+	if NewState().Root() != NewBuilder(State{}).Build().Root() {
+		t.Error("Empty states have different roots")
+	}
+}
@@ -12,12 +12,37 @@ type Node struct {
 	pop bls.Pop
 }
 
+// NodeRecord -- machine-readable representation of a Node
+type NodeRecord struct {
+	Address string `json:"addr"`
+	Pubkey  string `json:"pub"`
+	Pop     string `json:"pop"`
+}
+
 // Constructors
 
 // NodeFromSeckey --
-func NodeFromSeckey(sec bls.Seckey) Node {
-	pub := bls.PubkeyFromSeckey(sec)
-	return Node{pub, bls.GeneratePop(sec, pub)}
+func NodeFromSeckey(sec bls.Seckey) (Node, error) {
+	pub, err := bls.PubkeyFromSeckey(sec)
+	if err != nil {
+		return Node{}, err
+	}
+	pop, err := bls.GeneratePop(sec, pub)
+	return Node{pub, pop}, err
+}
+
+// NodeFromRecord -- inverse of Record
+// Fails if the address does not belong to the pubkey. The proof-of-possession is checked when the node is added to a state.
+func NodeFromRecord(r NodeRecord) (Node, error) {
+	n := Node{bls.PubkeyFromString(r.Pubkey), bls.Pop(bls.SignatureFromString(r.Pop))}
+	if _, err := n.pub.PublicKey(); err != nil {
+		return Node{}, err
+	}
+	if n.Address().Hex() != r.Address {
+		logger.Error("node record does not match its pubkey", "addr", r.Address)
+		return Node{}, ErrInvalidRecord
+	}
+	return n, nil
 }
 
 // Getters
@@ -27,11 +52,21 @@ func (n Node) Address() common.Address {
 	return n.pub.Address()
 }
 
+// Pubkey --
+func (n Node) Pubkey() bls.Pubkey {
+	return n.pub
+}
+
 // ID --
 func (n Node) ID() bls.ID {
 	return bls.IDFromBig(n.Address().Big())
 }
 
+// Hash -- the value of the node in the state's Merkle tree, a hash over pubkey and proof-of-possession
+func (n Node) Hash() common.Hash {
+	return keccak(lengthPrefixed([]byte(n.pub.String()), []byte(bls.Signature(n.pop).String())))
+}
+
 // hasPop --
 func (n Node) hasPop() bool {
 	return bls.VerifyPop(n.pub, n.pop)
@@ -44,6 +79,11 @@ func (n Node) Log() {
 	fmt.Println("    pop: ", n.pop)
 }
 
+// Record -- full (untruncated) representation for structured output
+func (n Node) Record() NodeRecord {
+	return NodeRecord{n.Address().Hex(), n.pub.String(), bls.Signature(n.pop).String()}
+}
+
 // String --
 func (n Node) String() string {
 	a := n.pub.Address()
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"github.com/ethereum/go-ethereum/common"
+	"sort"
+)
+
+// The random output of a block ranks all registered nodes as proposers of the next block.
+// Each node has a priority derived from the random output and its address, lower priorities rank higher.
+// A proposer proves its priority with the inclusion proof of its node, anyone can recompute the priority
+// from the random output, which is verified with the group signature.
+
+// Priority -- the priority of node a as block proposer after the random output r, lower is better
+func Priority(r bls.Rand, a common.Address) bls.Rand {
+	return r.Ders("proposer").DerivedRand(a[:])
+}
+
+// ProposerRanking -- all registered nodes ordered by their priority as proposer of the next block
+func (s State) ProposerRanking() []common.Address {
+	r := s.Rand()
+	nodes := s.NodeAddressList()
+	prio := make(map[common.Address]bls.Rand, len(nodes))
+	for _, a := range nodes {
+		prio[a] = Priority(r, a)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		pi, pj := prio[nodes[i]], prio[nodes[j]]
+		return bytes.Compare(pi[:], pj[:]) < 0
+	})
+	return nodes
+}
+
+// ProposerRank -- the position of node a in the ProposerRanking, 0 is the highest
+func (s State) ProposerRank(a common.Address) (int, bool) {
+	for i, b := range s.ProposerRanking() {
+		if b == a {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// VerifyProposer -- the priority of node n as proposer after the block with the given state root and random output
+// Fails if the proof does not show that n is registered in that state.
+func VerifyProposer(root common.Hash, r bls.Rand, n Node, p Proof) (bls.Rand, bool) {
+	if !VerifyNodeProof(root, n.Address(), &n, p) {
+		return bls.Rand{}, false
+	}
+	return Priority(r, n.Address()), true
+}
@@ -0,0 +1,60 @@
+package state
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"testing"
+)
+
+func TestProposerRanking(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	nodes := make([]Node, 9)
+	b := NewBuilder(NewState())
+	for i := range nodes {
+		nodes[i], _ = NodeFromSeckey(bls.SeckeyFromInt(int64(i + 1)))
+		// the last node is not registered
+		if i < len(nodes)-1 {
+			b.AddNode(nodes[i])
+		}
+	}
+	sig, _ := bls.Sign(bls.SeckeyFromInt(1), []byte("hi"))
+	b.SetSignature(sig)
+	s := b.Build()
+
+	ranking := s.ProposerRanking()
+	if len(ranking) != len(nodes)-1 {
+		t.Fatal("Ranking does not contain all registered nodes")
+	}
+	r := s.Rand()
+	for i := 1; i < len(ranking); i++ {
+		p, q := Priority(r, ranking[i-1]), Priority(r, ranking[i])
+		if bytes.Compare(p[:], q[:]) >= 0 {
+			t.Error("Ranking is not ordered by priority")
+		}
+	}
+	if rank, ok := s.ProposerRank(ranking[3]); !ok || rank != 3 {
+		t.Error("Wrong rank", rank, ok)
+	}
+
+	// a registered proposer proves its priority, an unregistered one cannot
+	for i, n := range nodes {
+		prio, ok := VerifyProposer(s.Root(), r, n, s.ProveNode(n.Address()))
+		if ok != (i < len(nodes)-1) || (ok && prio != Priority(r, n.Address())) {
+			t.Error("VerifyProposer is wrong for node", i)
+		}
+	}
+
+	// another random output gives another ranking
+	b = NewBuilder(s)
+	sig, _ = bls.Sign(bls.SeckeyFromInt(2), []byte("hi"))
+	b.SetSignature(sig)
+	other := b.Build().ProposerRanking()
+	same := true
+	for i := range ranking {
+		same = same && ranking[i] == other[i]
+	}
+	if same {
+		t.Error("Ranking does not depend on the random output")
+	}
+}
@@ -0,0 +1,80 @@
+package state
+
+import (
+	"dfinity/beacon/bls"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"io"
+)
+
+// SnapshotRecord -- canonical serialization of the State of the block at Height
+// Nodes and groups are sorted by address, so equal states have identical snapshots. Only what the state root
+// commits to is included: the proposer and the certificates of the block are left out.
+type SnapshotRecord struct {
+	Height    int           `json:"height"`
+	Root      string        `json:"root"`
+	Signature string        `json:"sig"`
+	Nodes     []NodeRecord  `json:"nodes"`
+	Groups    []GroupRecord `json:"groups"`
+}
+
+// Snapshot -- the snapshot of the state, which belongs to the block at the given height
+func (s State) Snapshot(height int) SnapshotRecord {
+	snap := SnapshotRecord{Height: height, Root: s.Root().Hex(), Signature: s.sig.String()}
+	snap.Nodes = make([]NodeRecord, 0, len(s.nodes))
+	for _, a := range s.NodeAddressList() {
+		snap.Nodes = append(snap.Nodes, s.nodes[a].Record())
+	}
+	snap.Groups = make([]GroupRecord, 0, len(s.groups))
+	for _, a := range s.GroupAddressList() {
+		snap.Groups = append(snap.Groups, s.groups[a].Record())
+	}
+	return snap
+}
+
+// State -- rebuild the state from the snapshot and check it against the trusted state root
+// All proofs-of-possession are verified. The root stored in the snapshot itself is not trusted.
+func (snap SnapshotRecord) State(root common.Hash) (State, error) {
+	b := NewBuilder(NewState())
+	for _, r := range snap.Nodes {
+		n, err := NodeFromRecord(r)
+		if err != nil {
+			return State{}, err
+		}
+		if err := b.AddNode(n); err != nil {
+			return State{}, err
+		}
+	}
+	for _, r := range snap.Groups {
+		g, err := GroupFromRecord(r)
+		if err != nil {
+			return State{}, err
+		}
+		if err := b.AddGroup(g); err != nil {
+			return State{}, err
+		}
+	}
+	b.SetSignature(bls.SignatureFromString(snap.Signature))
+	s := b.Build()
+	if s.Root() != root || s.Root().Hex() != snap.Root || len(s.nodes) != len(snap.Nodes) || len(s.groups) != len(snap.Groups) {
+		logger.Error("rejected snapshot", "height", snap.Height, "root", s.Root().Hex(), "want", root.Hex())
+		return State{}, ErrSnapshotRoot
+	}
+	return s, nil
+}
+
+// WriteSnapshot -- write the snapshot of the state at the given height as JSON
+func (s State) WriteSnapshot(w io.Writer, height int) error {
+	return json.NewEncoder(w).Encode(s.Snapshot(height))
+}
+
+// ReadSnapshot -- read a snapshot written by WriteSnapshot and check it against the trusted state root
+// Returns the state and the height of its block.
+func ReadSnapshot(r io.Reader, root common.Hash) (State, int, error) {
+	var snap SnapshotRecord
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return State{}, 0, err
+	}
+	s, err := snap.State(root)
+	return s, snap.Height, err
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	b := NewBuilder(NewState())
+	secs := make([]bls.Seckey, 4)
+	addrs := make([]common.Address, len(secs))
+	for i := range secs {
+		secs[i] = bls.SeckeyFromInt(int64(i + 1))
+		n, err := NodeFromSeckey(secs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs[i] = n.Address()
+		if err := b.AddNode(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// one group with and one without verification vector
+	g, _ := NewGroup(addrs[:3], 2, IDByIndex, 3, 1)
+	vvec, _ := bls.VerificationVectorFromSeckeys(secs[:2])
+	if err := g.SetVerificationVector(vvec); err != nil {
+		t.Fatal(err)
+	}
+	h, _ := NewGroup(addrs[1:], 3, IDByAddress, 5, 0)
+	h.SetPubkey(vvec.Pubkey())
+	b.AddGroup(g)
+	b.AddGroup(h)
+	sig, _ := bls.Sign(secs[0], []byte("hi"))
+	b.SetSignature(sig)
+	s := b.Build()
+
+	var buf bytes.Buffer
+	if err := s.WriteSnapshot(&buf, 7); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.String()
+	s2, height, err := ReadSnapshot(strings.NewReader(data), s.Root())
+	if err != nil || height != 7 {
+		t.Fatal("ReadSnapshot failed", height, err)
+	}
+	if s2.Root() != s.Root() || s2.Signature().String() != sig.String() {
+		t.Error("Snapshot does not restore the state")
+	}
+	if g2, ok := s2.Group(g.Address()); !ok || g2.VerificationVector().String() != vvec.String() || g2.IDMode() != IDByIndex {
+		t.Error("Snapshot does not restore the group")
+	}
+	var buf2 bytes.Buffer
+	s2.WriteSnapshot(&buf2, 7)
+	if buf2.String() != data {
+		t.Error("Snapshot is not canonical")
+	}
+
+	// a different trusted root or a modified snapshot is rejected
+	if _, _, err := ReadSnapshot(strings.NewReader(data), common.Hash{}); err != ErrSnapshotRoot {
+		t.Error("Expected ErrSnapshotRoot, got", err)
+	}
+	snap := s.Snapshot(7)
+	snap.Groups[0].Nonce++
+	if _, err := snap.State(s.Root()); err != ErrInvalidRecord {
+		t.Error("Expected ErrInvalidRecord, got", err)
+	}
+	snap = s.Snapshot(7)
+	snap.Groups[1].Pubkey = snap.Nodes[0].Pubkey
+	if _, err := snap.State(s.Root()); err != ErrSnapshotRoot {
+		t.Error("Expected ErrSnapshotRoot, got", err)
+	}
+	// the signature determines the beacon output, a forged one is rejected
+	forged, _ := bls.Sign(secs[1], []byte("hi"))
+	snap = s.Snapshot(7)
+	snap.Signature = forged.String()
+	if _, err := snap.State(s.Root()); err != ErrSnapshotRoot {
+		t.Error("Expected ErrSnapshotRoot for a forged signature, got", err)
+	}
+}
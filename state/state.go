@@ -3,18 +3,73 @@ package state
 import (
 	"dfinity/beacon/bls"
 	dfn "dfinity/beacon/common"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 )
 
 // State -- encodes the state of the chain (state of 1 block)
+// A State is immutable, new states are derived with a Builder. States share the maps they did not change.
 type State struct {
 	nodes  map[common.Address]Node
 	groups map[common.Address]Group
 	sig    bls.Signature
+	// the node that proposed the block, zero if unknown
+	proposer common.Address
+	// certificates of the groups registered in the block
+	certs []Certificate
+	// roots of the Merkle trees over nodes and groups, computed by the Builder
+	nodeRoot, groupRoot common.Hash
 }
 
-// NewState --
+// StateRecord -- machine-readable representation of a State
+type StateRecord struct {
+	Signature string              `json:"sig"`
+	Rand      string              `json:"rnd"`
+	Nodes     int                 `json:"N"`
+	Groups    int                 `json:"m"`
+	Selected  string              `json:"grp"`
+	Root      string              `json:"root"`
+	Proposer  string              `json:"proposer,omitempty"`
+	Certs     []CertificateRecord `json:"certs,omitempty"`
+}
+
+// ErrInvalidPop -- the node's proof-of-possession does not verify
+var ErrInvalidPop = errors.New("state: invalid proof-of-possession")
+
+// ErrInvalidGroup -- the group is not valid
+var ErrInvalidGroup = errors.New("state: invalid group")
+
+// ErrNotMember -- the address is not a member of the group
+var ErrNotMember = errors.New("state: not a group member")
+
+// ErrNoVvec -- the group has no verification vector
+var ErrNoVvec = errors.New("state: group has no verification vector")
+
+// ErrInvalidRefresh -- a share refresh would change the group pubkey
+var ErrInvalidRefresh = errors.New("state: refresh is not a sharing of zero")
+
+// ErrInvalidHandover -- a handover would change the group pubkey
+var ErrInvalidHandover = errors.New("state: handover changes the group pubkey")
+
+// ErrInvalidRecord -- a record does not match the content it claims to represent
+var ErrInvalidRecord = errors.New("state: inconsistent record")
+
+// ErrInvalidCertificate -- a group certificate is not signed by a group of the parent state, or not for a registered group
+var ErrInvalidCertificate = errors.New("state: invalid group certificate")
+
+// ErrSnapshotRoot -- a snapshot does not match the trusted state root
+var ErrSnapshotRoot = errors.New("state: snapshot does not match state root")
+
+var logger dfn.Logger = dfn.NopLogger{}
+
+// SetLogger -- set the logger used by the package (default discards everything)
+func SetLogger(l dfn.Logger) {
+	logger = l
+}
+
+// NewState -- the empty state, parent of the genesis block
 func NewState() State {
 	s := State{}
 	s.nodes = make(map[common.Address]Node)
@@ -22,27 +77,14 @@ func NewState() State {
 	return s
 }
 
-// AddNode --
-func (s *State) AddNode(n Node) (valid bool) {
-	valid = n.hasPop()
-	if valid {
-		s.nodes[n.Address()] = n
-	}
-	return
-}
-
-// AddGroup --
-func (s *State) AddGroup(g Group) (valid bool) {
-	valid = g.isValid()
-	if valid {
-		s.groups[g.Address()] = g
-	}
-	return
+// Signature --
+func (s State) Signature() bls.Signature {
+	return s.sig
 }
 
-// SetSignature --
-func (s *State) SetSignature(sig bls.Signature) {
-	s.sig = sig
+// Proposer -- the node that proposed the block, see ProposerRanking
+func (s State) Proposer() common.Address {
+	return s.proposer
 }
 
 // Rand --
@@ -63,10 +105,10 @@ func (s State) NodeAddressList() []common.Address {
 	return addresses
 }
 
-// NewRandomGroup --
-func (s State) NewRandomGroup(r bls.Rand, n uint16) Group {
+// NewRandomGroup -- a group of n random nodes with threshold k, formed at the given height
+func (s State) NewRandomGroup(r bls.Rand, n uint16, k uint16, mode IDMode, height uint64, nonce uint64) (Group, error) {
 	N := len(s.nodes) // need n <= N
-	fmt.Println(N, n)
+	logger.Debug("new random group", "N", N, "n", n)
 	// get sorted list of nodes
 	nodes := s.NodeAddressList()
 	// choose members based on r
@@ -75,7 +117,7 @@ func (s State) NewRandomGroup(r bls.Rand, n uint16) Group {
 	for j, idx := range indices {
 		members[j] = nodes[idx]
 	}
-	return Group{members, bls.Pubkey{}, 0}
+	return NewGroup(members, k, mode, height, nonce)
 }
 
 // GroupAddressList --
@@ -97,6 +139,39 @@ func (s State) SelectedGroupAddress() common.Address {
 	return s.GroupAddressList()[i]
 }
 
+// Node -- the node registered under a, if any
+func (s State) Node(a common.Address) (Node, bool) {
+	n, ok := s.nodes[a]
+	return n, ok
+}
+
+// Group -- the group registered under a, if any
+func (s State) Group(a common.Address) (Group, bool) {
+	g, ok := s.groups[a]
+	return g, ok
+}
+
+// Root -- the state root, which commits to all nodes and groups and to the signature of the block
+// Entries are proven with ProveNode and ProveGroup.
+func (s State) Root() common.Hash {
+	return stateRoot(s.nodeRoot, s.groupRoot, s.sigHash())
+}
+
+// sigHash -- the hash of the signature in the state root
+func (s State) sigHash() common.Hash {
+	return keccak([]byte(s.sig.String()))
+}
+
+// ProveNode -- proof that the node registered under a is in the state, or that there is none
+func (s State) ProveNode(a common.Address) Proof {
+	return proof(nodeLeaves(s.nodes), a, s.groupRoot, s.sigHash())
+}
+
+// ProveGroup -- proof that the group registered under a is in the state, or that there is none
+func (s State) ProveGroup(a common.Address) Proof {
+	return proof(groupLeaves(s.groups), a, s.nodeRoot, s.sigHash())
+}
+
 // GroupPubkey --
 func (s State) GroupPubkey(a common.Address) bls.Pubkey {
 	return s.groups[a].pub
@@ -124,10 +199,29 @@ func (s State) Log() {
 	fmt.Printf("    %d. % x\n", 1, s.SelectedGroupAddress())
 }
 
+// Record -- full (untruncated) representation for structured output
+func (s State) Record() StateRecord {
+	rec := StateRecord{s.sig.String(), hex.EncodeToString(s.Rand().Bytes()), len(s.nodes), len(s.groups), s.SelectedGroupAddress().Hex(), s.Root().Hex(), "", nil}
+	if s.proposer != (common.Address{}) {
+		rec.Proposer = s.proposer.Hex()
+	}
+	for _, c := range s.certs {
+		rec.Certs = append(rec.Certs, c.Record())
+	}
+	return rec
+}
+
 // String --
 func (s State) String(long bool) string {
 	rnd := s.Rand().Bytes()
-	str := fmt.Sprintf("Stat: (sig)%.8s (rnd)%.2x (N)%d (m)%d (grp)%.2x", s.sig.String(), rnd, len(s.nodes), len(s.groups), s.SelectedGroupAddress())
+	root := s.Root()
+	str := fmt.Sprintf("Stat: (sig)%.8s (rnd)%.2x (N)%d (m)%d (grp)%.2x (root)%.2x", s.sig.String(), rnd, len(s.nodes), len(s.groups), s.SelectedGroupAddress(), root[:2])
+	if s.proposer != (common.Address{}) {
+		str += fmt.Sprintf(" (prop)%.2x", s.proposer[:2])
+	}
+	if len(s.certs) > 0 {
+		str += fmt.Sprintf(" (certs)%d", len(s.certs))
+	}
 	if long {
 		str += "\n"
 		for i, a := range s.NodeAddressList() {
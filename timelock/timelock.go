@@ -0,0 +1,170 @@
+// Package timelock encrypts payloads to future rounds of the random beacon
+//
+// The output of a round is the unique BLS signature of the selected group on a message known in advance,
+// so (group pubkey, message) works as an identity for Boneh-Franklin identity-based encryption. The key
+// of a ciphertext is derived from e(r*H(msg), pub) = e(sig, r*G2): the sender knows r, anybody who has the
+// signature of the round knows the right-hand side. Until the group signs, nobody (not even the sender,
+// once r is discarded) can decrypt.
+//
+// The message of a round is the random output of the previous block, so the identity of a round is known
+// as soon as its previous block is: ciphertexts target the round after the latest block.
+package timelock
+
+import (
+	"crypto/rand"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	dfn "dfinity/beacon/common"
+	"dfinity/beacon/state"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"io"
+	"strings"
+)
+
+// Errors
+
+// ErrSignature -- the signature is not the output of the round the ciphertext targets
+var ErrSignature = errors.New("timelock: signature does not match the round")
+
+// ErrAuth -- the ciphertext was modified
+var ErrAuth = errors.New("timelock: authentication failed")
+
+// ErrRecord -- a ciphertext record cannot be decoded
+var ErrRecord = errors.New("timelock: bad ciphertext record")
+
+// types
+
+// Round -- a round of the beacon, identified by the group that signs it and the message it signs
+type Round struct {
+	// height of the block produced by the round
+	Height int
+	Pubkey bls.Pubkey
+	Msg    []byte
+}
+
+// Ciphertext -- a payload encrypted to a round
+type Ciphertext struct {
+	Round
+	// r*G2 for the random r of the sender
+	U    bls.Pubkey
+	Body []byte
+	Tag  common.Hash
+}
+
+// NextRound -- the round after the block at the given height with state s
+func NextRound(s state.State, height int) Round {
+	return Round{height + 1, s.SelectedGroupPubkey(), s.Rand().Bytes()}
+}
+
+// Encrypt -- encrypt the payload so that it can be decrypted with the signature of the round
+func Encrypt(round Round, payload []byte) (Ciphertext, error) {
+	return EncryptFrom(rand.Reader, round, payload)
+}
+
+// EncryptFrom -- Encrypt with randomness from the given reader
+func EncryptFrom(rnd io.Reader, round Round, payload []byte) (c Ciphertext, err error) {
+	var b [bls.SeckeyLength]byte
+	if _, err = io.ReadFull(rnd, b[:]); err != nil {
+		return
+	}
+	r := bls.SeckeyFromBytes(b[:])
+	defer r.Destroy()
+	if c.U, err = bls.PubkeyFromSeckey(r); err != nil {
+		return
+	}
+	rs, err := r.SecretKey()
+	if err != nil {
+		return
+	}
+	defer rs.Clear()
+	pk, err := round.Pubkey.PublicKey()
+	if err != nil {
+		return
+	}
+	q := blscgo.HashToSign(string(round.Msg))
+	q.Mul(rs)
+	c.Round = round
+	c.Body, c.Tag = dfn.Seal(c.key(blscgo.Pairing(q, pk)), payload)
+	return
+}
+
+// Decrypt -- decrypt with the signature of the round
+// The signature is verified first, a valid signature on a modified ciphertext fails authentication.
+func Decrypt(c Ciphertext, sig bls.Signature) ([]byte, error) {
+	if !bls.VerifySig(c.Pubkey, c.Msg, sig) {
+		return nil, ErrSignature
+	}
+	sign, err := sig.Sig()
+	if err != nil {
+		return nil, err
+	}
+	u, err := c.U.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	payload, ok := dfn.Open(c.key(blscgo.Pairing(sign, u)), c.Body, c.Tag)
+	if !ok {
+		return nil, ErrAuth
+	}
+	return payload, nil
+}
+
+// key -- the symmetric key derived from the pairing value, bound to the round and U
+func (c Ciphertext) key(e *blscgo.GT) common.Hash {
+	var h [8]byte
+	binary.BigEndian.PutUint64(h[:], uint64(c.Height))
+	return crypto.Keccak256Hash([]byte("timelock"), []byte(e.String()), []byte(c.U.String()), []byte(c.Pubkey.String()), h[:], c.Msg)
+}
+
+// Serialization
+
+// CiphertextRecord --
+type CiphertextRecord struct {
+	Height int    `json:"height"`
+	Pubkey string `json:"pub"`
+	Msg    string `json:"msg"`
+	U      string `json:"u"`
+	Body   string `json:"body"`
+	Tag    string `json:"tag"`
+}
+
+// Record --
+func (c Ciphertext) Record() CiphertextRecord {
+	return CiphertextRecord{c.Height, c.Pubkey.String(), hex.EncodeToString(c.Msg), c.U.String(), hex.EncodeToString(c.Body), c.Tag.Hex()}
+}
+
+// CiphertextFromRecord --
+func CiphertextFromRecord(r CiphertextRecord) (c Ciphertext, err error) {
+	c.Height, c.Pubkey, c.U = r.Height, bls.PubkeyFromString(r.Pubkey), bls.PubkeyFromString(r.U)
+	if c.Msg, err = hex.DecodeString(r.Msg); err != nil {
+		return Ciphertext{}, ErrRecord
+	}
+	if c.Body, err = hex.DecodeString(r.Body); err != nil {
+		return Ciphertext{}, ErrRecord
+	}
+	tag, err := hex.DecodeString(strings.TrimPrefix(r.Tag, "0x"))
+	if err != nil || len(tag) != common.HashLength {
+		return Ciphertext{}, ErrRecord
+	}
+	c.Tag = common.BytesToHash(tag)
+	return c, nil
+}
+
+// Write -- write the ciphertext as JSON
+func (c Ciphertext) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c.Record())
+}
+
+// Read -- read a ciphertext written by Write
+func Read(r io.Reader) (Ciphertext, error) {
+	var rec CiphertextRecord
+	if err := json.NewDecoder(r).Decode(&rec); err != nil {
+		return Ciphertext{}, err
+	}
+	return CiphertextFromRecord(rec)
+}
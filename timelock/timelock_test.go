@@ -0,0 +1,71 @@
+package timelock
+
+import (
+	"bytes"
+	"dfinity/beacon/bls"
+	"dfinity/beacon/blscgo"
+	"dfinity/beacon/sim"
+	"testing"
+)
+
+func TestTimelock(t *testing.T) {
+	blscgo.Init(blscgo.CurveFp254BNb)
+	defer func(f string) { sim.Format = f }(sim.Format)
+	sim.Format = sim.FormatNone
+	chain, err := sim.NewBlockchainSimulator(bls.RandFromBytes([]byte("timelock")), 3, 2, 8, 3, sim.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Advance(3, false); err != nil {
+		t.Fatal(err)
+	}
+	round := NextRound(chain.Tip(), chain.Length())
+	payload := []byte("a payload longer than a single block of the keystream")
+	c, err := Encrypt(round, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(c.Body, payload[:8]) {
+		t.Error("Body contains the payload")
+	}
+
+	// the signature of an earlier round does not decrypt
+	if _, err := Decrypt(c, chain.Tip().Signature()); err != ErrSignature {
+		t.Error("Decrypted before the round:", err)
+	}
+	if err := chain.Advance(2, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(c, chain.Block(round.Height+1).Signature()); err != ErrSignature {
+		t.Error("Decrypted with the signature of a later round:", err)
+	}
+
+	// round trip through the record
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := chain.Block(round.Height).Signature()
+	out, err := Decrypt(read, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, payload) {
+		t.Errorf("Decrypted %q, want %q", out, payload)
+	}
+
+	// modifications are detected
+	read.Body[0] ^= 1
+	if _, err := Decrypt(read, sig); err != ErrAuth {
+		t.Error("Modified body not detected:", err)
+	}
+	read.Body[0] ^= 1
+	read.U = c.Pubkey
+	if _, err := Decrypt(read, sig); err != ErrAuth {
+		t.Error("Modified U not detected:", err)
+	}
+}